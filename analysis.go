@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// DetectPortScan reports whether srcIP sent connection-opening SYNs to more
+// than threshold distinct destination ports, the signature of a horizontal
+// scan. The header carries no addresses, so srcIP does not filter pkts: the
+// caller must pass only the packets sent by srcIP. srcIP merely names the
+// sender, and when it is empty they cannot be attributed and nothing is
+// reported.
+func DetectPortScan(pkts []*Packet, srcIP string, threshold int) bool {
+
+	if srcIP == "" {
+		return false
+	}
+
+	ports := make(map[uint16]struct{})
+	for _, p := range pkts {
+		if p.hasFlag(FlagSYN) && !p.hasFlag(FlagACK) {
+			ports[p.DestinationPort()] = struct{}{}
+		}
+	}
+	return len(ports) > threshold
+}
+
+// DetectRSTAfterFIN reports whether a RST follows a FIN in the same
+// direction of a flow, which usually means an application abort or a
+// middlebox. pkts must be in capture order; direction is told by ports.
+func DetectRSTAfterFIN(pkts []*Packet) bool {
+
+	finSeen := make(map[[2]uint16]bool)
+	for _, p := range pkts {
+		dir := [2]uint16{p.SourcePort(), p.DestinationPort()}
+		if p.hasFlag(FlagRST) && finSeen[dir] {
+			return true
+		}
+		if p.hasFlag(FlagFIN) {
+			finSeen[dir] = true
+		}
+	}
+	return false
+}
+
+// ShouldNagleHold reports whether Nagle's algorithm (RFC 896) would hold back
+// a segment of payloadLen bytes: it is smaller than the MSS and earlier data
+// is still unacknowledged.
+func ShouldNagleHold(payloadLen int, mss uint16, unackedData bool) bool {
+
+	return payloadLen > 0 && payloadLen < int(mss) && unackedData
+}
+
+// InferCwnd estimates the sender's congestion window as the most bytes in
+// flight just before an ACK arrived: the highest sequence number sent so far
+// less the highest cumulative ACK seen so far. segments are the data of one
+// direction and acks the ACKs of the other; times holds the capture times of
+// segments followed by those of acks, as for SummarizeFlow, and is what
+// puts the two in order. Packets without a time are left out. An ACK and a
+// segment captured at the same instant are taken as the segment first.
+func InferCwnd(segments []Segment, acks []*Packet, times []time.Time) uint32 {
+
+	type event struct {
+		t   time.Time
+		seg *Segment
+		ack *Packet
+	}
+	var events []event
+	for i := range segments {
+		if i < len(times) {
+			events = append(events, event{t: times[i], seg: &segments[i]})
+		}
+	}
+	for j, a := range acks {
+		if i := len(segments) + j; i < len(times) {
+			events = append(events, event{t: times[i], ack: a})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].t.Before(events[j].t) })
+
+	var una, sent, cwnd uint32
+	started := false
+	for _, e := range events {
+		if e.seg != nil {
+			start, end := e.seg.Packet.DataStartSeq(), e.seg.Packet.NextSeq(len(e.seg.Payload))
+			switch {
+			case !started:
+				una, sent, started = start, end, true
+			case seqLT(sent, end):
+				sent = end
+			}
+			continue
+		}
+		if !started || !e.ack.hasFlag(FlagACK) {
+			continue
+		}
+		if flight := sent - una; seqLT(una, sent) && flight > cwnd {
+			cwnd = flight
+		}
+		if ack := e.ack.AckNumber(); seqLT(una, ack) {
+			una = ack
+		}
+	}
+	return cwnd
+}
+
+// IsKeepAlive reports whether p looks like a keepalive probe: a bare ACK
+// whose sequence number is one below nextSeq, the next sequence number the
+// sender would use (RFC 9293 section 3.8.4).
+func IsKeepAlive(p *Packet, nextSeq uint32) bool {
+
+	return p.flagBits()&^(FlagPSH|FlagECE|FlagCWR|FlagNS) == FlagACK && p.SequenceNumber() == nextSeq-1
+}
+
+// DetectKeepAliveStorm reports whether more than threshold keepalive probes
+// fall within any span of window. pkts and times are read in parallel and
+// in capture order. Payload lengths are not known here, so the next
+// sequence number of each direction is taken from its last non-probe
+// packet, which holds on an idle connection where probes are sent.
+func DetectKeepAliveStorm(pkts []*Packet, window time.Duration, times []time.Time, threshold int) bool {
+
+	next := make(map[[2]uint16]uint32)
+	var probes []time.Time
+	for i, p := range pkts {
+		if i >= len(times) {
+			break
+		}
+		dir := [2]uint16{p.SourcePort(), p.DestinationPort()}
+		seq, seen := next[dir]
+		if !seen || !IsKeepAlive(p, seq) {
+			next[dir] = p.SequenceNumber()
+			continue
+		}
+
+		probes = append(probes, times[i])
+		for len(probes) > 0 && times[i].Sub(probes[0]) > window {
+			probes = probes[1:]
+		}
+		if len(probes) > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSegmentObserved returns the largest payload among segments, the MSS the
+// flow actually reached as opposed to the one advertised.
+func MaxSegmentObserved(segments []Segment) int {
+
+	max := 0
+	for _, s := range segments {
+		if len(s.Payload) > max {
+			max = len(s.Payload)
+		}
+	}
+	return max
+}
+
+// RSTHasPayload reports whether p is a RST and segment, the whole TCP
+// segment p was read from, carries data past the header. Some stacks put a
+// human-readable reset reason there (RFC 9293 section 3.5.3).
+func (p *Packet) RSTHasPayload(segment []byte) bool {
+
+	return p.hasFlag(FlagRST) && len(segment) > p.headerLen()
+}
+
+// DetectAsymmetry reports whether either direction of a flow acknowledges
+// data never seen in the other, meaning that direction is not fully
+// captured, typically because of asymmetric routing. fwd and rev hold the
+// segments of each direction; an ACK-bearing direction facing an empty one
+// counts as asymmetric.
+func DetectAsymmetry(fwd, rev []Segment) bool {
+
+	return acksUnseenData(fwd, rev) || acksUnseenData(rev, fwd)
+}
+
+// acksUnseenData reports whether an ACK in acks acknowledges beyond the
+// highest sequence number seen in data.
+func acksUnseenData(data, acks []Segment) bool {
+
+	var highest uint32
+	seen := false
+	for _, s := range data {
+		end := s.Packet.NextSeq(len(s.Payload))
+		if !seen || seqLT(highest, end) {
+			highest, seen = end, true
+		}
+	}
+
+	for _, a := range acks {
+		if !a.Packet.hasFlag(FlagACK) {
+			continue
+		}
+		if !seen || seqLT(highest, a.Packet.AckNumber()) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectTailLoss reports whether the last data of a flow was never
+// cumulatively acknowledged: the highest ACK in acks stops short of the end
+// of the highest data segment, the tail loss case RACK-TLP targets. With no
+// ACKs at all the capture is more likely incomplete and nothing is reported.
+func DetectTailLoss(segments []Segment, acks []*Packet) bool {
+
+	var end uint32
+	haveData := false
+	for _, s := range segments {
+		if len(s.Payload) == 0 {
+			continue
+		}
+		if e := s.Packet.NextSeq(len(s.Payload)); !haveData || seqLT(end, e) {
+			end, haveData = e, true
+		}
+	}
+
+	var acked uint32
+	haveAck := false
+	for _, a := range acks {
+		if !a.hasFlag(FlagACK) {
+			continue
+		}
+		if ack := a.AckNumber(); !haveAck || seqLT(acked, ack) {
+			acked, haveAck = ack, true
+		}
+	}
+
+	return haveData && haveAck && seqLT(acked, end)
+}
+
+// DetectPMTUBlackhole reports the signature of a path MTU black hole: the
+// largest segments of the flow are retransmitted more than once while
+// smaller ones are still being sent, often re-covering the same range once
+// the sender falls back. segments are one direction in capture order.
+func DetectPMTUBlackhole(segments []Segment) bool {
+
+	large := MaxSegmentObserved(segments)
+	if large == 0 {
+		return false
+	}
+
+	var next uint32
+	seen := false
+	largeRetrans, smallSent := 0, 0
+	for _, s := range segments {
+		n := len(s.Payload)
+		if n == 0 {
+			continue
+		}
+		retrans := seen && s.Packet.IsRetransmission(next, n)
+		switch {
+		case retrans && n == large:
+			largeRetrans++
+		case n < large:
+			smallSent++
+		}
+		if end := s.Packet.NextSeq(n); !seen || seqLT(next, end) {
+			next, seen = end, true
+		}
+	}
+	return largeRetrans > 1 && smallSent > 0
+}
+
+// DetectTimestampAnomaly reports whether a sender's TSval goes backwards
+// within a flow. TSval only ever rises or stays put, so a regression points
+// to an injected or spoofed segment. pkts are in capture order, directions
+// are told apart by ports and packets without timestamps are skipped.
+func DetectTimestampAnomaly(pkts []*Packet) bool {
+
+	last := make(map[[2]uint16]uint32)
+	for _, p := range pkts {
+		tsval, _, ok := p.Timestamps()
+		if !ok {
+			continue
+		}
+		dir := [2]uint16{p.SourcePort(), p.DestinationPort()}
+		if prev, seen := last[dir]; seen && seqLT(tsval, prev) {
+			return true
+		}
+		last[dir] = tsval
+	}
+	return false
+}
+
+// IsExactDuplicate reports whether a and b are the same segment sent twice:
+// equal sequence numbers, flags and payload bytes. A retransmission that
+// re-covers the range with different data or segmentation is not one.
+func IsExactDuplicate(a, b *Packet, aPayload, bPayload []byte) bool {
+
+	return a.SequenceNumber() == b.SequenceNumber() &&
+		a.flagBits() == b.flagBits() &&
+		bytes.Equal(aPayload, bPayload)
+}
+
+// DetectDelayedAck reports whether ACKs consistently trail the data they
+// answer by at least threshold: for more than half of the data arrivals,
+// the next ACK comes threshold or later after it. Delayed ACK timers run
+// from about 40 to 200ms. Both slices must be sorted by time.
+func DetectDelayedAck(dataTimes, ackTimes []time.Time, threshold time.Duration) bool {
+
+	delayed, matched := 0, 0
+	j := 0
+	for _, d := range dataTimes {
+		for j < len(ackTimes) && ackTimes[j].Before(d) {
+			j++
+		}
+		if j == len(ackTimes) {
+			break
+		}
+		matched++
+		if ackTimes[j].Sub(d) >= threshold {
+			delayed++
+		}
+	}
+	return matched > 0 && delayed*2 > matched
+}
+
+// SegmentCount returns how many segments payloadLen bytes split into at the
+// given MSS, rounding up. An empty payload, or an MSS of zero, gives zero.
+func SegmentCount(payloadLen int, mss uint16) int {
+
+	if payloadLen <= 0 || mss == 0 {
+		return 0
+	}
+	return (payloadLen + int(mss) - 1) / int(mss)
+}
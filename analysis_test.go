@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// synsTo returns one SYN to each of the given ports.
+func synsTo(ports ...uint16) []*Packet {
+
+	pkts := make([]*Packet, len(ports))
+	for i, port := range ports {
+		pkts[i] = buildPacket(50000, port, uint32(i), 0, FlagSYN, 1024)
+	}
+	return pkts
+}
+
+func TestDetectPortScan(t *testing.T) {
+
+	pkts := synsTo(22, 80, 443, 443, 8080)
+	if DetectPortScan(pkts, "10.0.0.1", 4) {
+		t.Error("4 distinct ports tripped a threshold of 4")
+	}
+	if !DetectPortScan(pkts, "10.0.0.1", 3) {
+		t.Error("4 distinct ports did not trip a threshold of 3")
+	}
+	if DetectPortScan(pkts, "", 0) {
+		t.Error("reported a scan with no source address")
+	}
+
+	// SYN-ACKs answer a scan rather than make one.
+	synAcks := []*Packet{
+		buildPacket(50000, 22, 1, 1, FlagSYN|FlagACK, 1024),
+		buildPacket(50000, 80, 1, 1, FlagSYN|FlagACK, 1024),
+	}
+	if DetectPortScan(synAcks, "10.0.0.1", 1) {
+		t.Error("SYN-ACKs were counted as scan probes")
+	}
+}
+
+func TestDetectRSTAfterFIN(t *testing.T) {
+
+	fin := buildPacket(46926, 443, 100, 200, FlagFIN|FlagACK, 64240)
+	rst := buildPacket(46926, 443, 101, 0, FlagRST, 0)
+	peerRST := buildPacket(443, 46926, 200, 0, FlagRST, 0)
+
+	if !DetectRSTAfterFIN([]*Packet{fin, rst}) {
+		t.Error("FIN then RST in the same direction not detected")
+	}
+	if DetectRSTAfterFIN([]*Packet{rst, fin}) {
+		t.Error("RST before FIN detected")
+	}
+	if DetectRSTAfterFIN([]*Packet{fin, peerRST}) {
+		t.Error("RST from the other direction detected")
+	}
+}
+
+func TestShouldNagleHold(t *testing.T) {
+
+	tests := []struct {
+		payload int
+		unacked bool
+		want    bool
+	}{
+		{100, true, true},
+		{100, false, false},
+		{1460, true, false},
+		{0, true, false},
+	}
+	for _, tt := range tests {
+		if got := ShouldNagleHold(tt.payload, 1460, tt.unacked); got != tt.want {
+			t.Errorf("ShouldNagleHold(%d, 1460, %v) = %v, want %v", tt.payload, tt.unacked, got, tt.want)
+		}
+	}
+}
+
+func TestInferCwnd(t *testing.T) {
+
+	// A window of four 1000-byte segments from seq 1001, acknowledged two
+	// segments at a time by a delayed-ACK receiver: each ACK lets two more
+	// segments out, so four are in flight whenever an ACK arrives.
+	t0 := time.Unix(1700000000, 0)
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+	var segments []Segment
+	var times []time.Time
+	for i := 0; i < 8; i++ {
+		segments = append(segments, Segment{
+			Packet:  buildPacket(46926, 443, 1001+uint32(i)*1000, 1, FlagACK, 64240),
+			Payload: make([]byte, 1000),
+		})
+		// Segments 0-3 leave at once, the rest two per ACK.
+		if i < 4 {
+			times = append(times, ms(i))
+		} else {
+			times = append(times, ms(40*((i-2)/2)+1))
+		}
+	}
+	var acks []*Packet
+	for i := 1; i <= 4; i++ {
+		acks = append(acks, buildPacket(443, 46926, 1, 1001+uint32(i)*2000, FlagACK, 64240))
+		times = append(times, ms(40*i))
+	}
+
+	if got := InferCwnd(segments, acks, times); got != 4000 {
+		t.Errorf("InferCwnd = %d, want 4000", got)
+	}
+
+	// The same flow with the sender only ever two segments ahead.
+	narrow := make([]time.Time, len(times))
+	copy(narrow, times)
+	for i := range segments {
+		narrow[i] = ms(40*(i/2) + 1)
+	}
+	for i := range acks {
+		narrow[len(segments)+i] = ms(40*(i+1) - 1)
+	}
+	if got := InferCwnd(segments, acks, narrow); got != 2000 {
+		t.Errorf("two segments in flight: InferCwnd = %d, want 2000", got)
+	}
+
+	if got := InferCwnd(nil, acks, times[8:]); got != 0 {
+		t.Errorf("no data: InferCwnd = %d, want 0", got)
+	}
+	if got := InferCwnd(segments, acks, nil); got != 0 {
+		t.Errorf("no times: InferCwnd = %d, want 0", got)
+	}
+}
+
+func TestDetectKeepAliveStorm(t *testing.T) {
+
+	t0 := time.Unix(1700000000, 0)
+	last := buildPacket(46926, 443, 1000, 1, FlagACK, 64240)
+	probe := buildPacket(46926, 443, 999, 1, FlagACK, 64240)
+
+	storm := func(n int, gap time.Duration) ([]*Packet, []time.Time) {
+		pkts := []*Packet{last}
+		times := []time.Time{t0}
+		for i := 1; i <= n; i++ {
+			pkts = append(pkts, probe)
+			times = append(times, t0.Add(time.Duration(i)*gap))
+		}
+		return pkts, times
+	}
+
+	pkts, times := storm(4, 100*time.Millisecond)
+	if !DetectKeepAliveStorm(pkts, time.Second, times, 3) {
+		t.Error("4 probes in 400ms did not trip a threshold of 3")
+	}
+	if DetectKeepAliveStorm(pkts, time.Second, times, 4) {
+		t.Error("4 probes tripped a threshold of 4")
+	}
+
+	pkts, times = storm(10, 75*time.Second)
+	if DetectKeepAliveStorm(pkts, time.Minute, times, 1) {
+		t.Error("probes 75s apart tripped a one-minute window")
+	}
+}
+
+func TestMaxSegmentObserved(t *testing.T) {
+
+	segments := []Segment{dataSegment(1, "abc"), dataSegment(4, "abcdefgh"), dataSegment(12, ""), dataSegment(12, "ab")}
+	if got := MaxSegmentObserved(segments); got != 8 {
+		t.Errorf("MaxSegmentObserved = %d, want 8", got)
+	}
+	if got := MaxSegmentObserved(nil); got != 0 {
+		t.Errorf("no segments: MaxSegmentObserved = %d, want 0", got)
+	}
+}
+
+func TestRSTHasPayload(t *testing.T) {
+
+	rst := buildPacket(443, 46926, 5001, 1001, FlagRST|FlagACK, 0)
+	segment := append(append([]byte(nil), rst.Header...), "connection reset by policy"...)
+	if !rst.RSTHasPayload(segment) {
+		t.Error("RST with a reason text not reported")
+	}
+	if rst.RSTHasPayload(rst.Header) {
+		t.Error("bare RST reported")
+	}
+	ack := buildPacket(443, 46926, 5001, 1001, FlagACK, 0)
+	if ack.RSTHasPayload(segment) {
+		t.Error("non-RST reported")
+	}
+}
+
+func TestDetectAsymmetry(t *testing.T) {
+
+	fwd := []Segment{dataSegment(1001, "hello")}
+	ackSeen := Segment{Packet: buildPacket(443, 46926, 5001, 1006, FlagACK, 64240)}
+	ackUnseen := Segment{Packet: buildPacket(443, 46926, 5001, 3006, FlagACK, 64240)}
+
+	if DetectAsymmetry(fwd, []Segment{ackSeen}) {
+		t.Error("ACK of captured data reported")
+	}
+	if !DetectAsymmetry(fwd, []Segment{ackSeen, ackUnseen}) {
+		t.Error("ACK of 2000 uncaptured bytes not reported")
+	}
+	if !DetectAsymmetry(nil, []Segment{ackSeen}) {
+		t.Error("ACKs facing an empty direction not reported")
+	}
+}
+
+func TestDetectTailLoss(t *testing.T) {
+
+	segments := []Segment{dataSegment(1001, "first"), dataSegment(1006, "last")}
+	partial := []*Packet{buildPacket(443, 46926, 1, 1006, FlagACK, 64240)}
+	full := append(partial, buildPacket(443, 46926, 1, 1010, FlagACK, 64240))
+
+	if !DetectTailLoss(segments, partial) {
+		t.Error("unacknowledged last segment not reported")
+	}
+	if DetectTailLoss(segments, full) {
+		t.Error("fully acknowledged flow reported")
+	}
+	if DetectTailLoss(segments, nil) {
+		t.Error("reported with no ACKs captured")
+	}
+}
+
+func TestDetectPMTUBlackhole(t *testing.T) {
+
+	large := func(seq uint32) Segment {
+		return Segment{Packet: buildPacket(46926, 443, seq, 1, FlagACK, 64240), Payload: make([]byte, 1460)}
+	}
+	small := func(seq uint32) Segment {
+		return Segment{Packet: buildPacket(46926, 443, seq, 1, FlagACK, 64240), Payload: make([]byte, 536)}
+	}
+
+	// The 1460-byte segment is retransmitted twice, then the sender falls
+	// back to 536-byte segments over the same range.
+	blackhole := []Segment{small(1), large(537), large(537), large(537), small(537), small(1073)}
+	if !DetectPMTUBlackhole(blackhole) {
+		t.Error("black hole signature not detected")
+	}
+	if DetectPMTUBlackhole(blackhole[1:4]) {
+		t.Error("detected with only large segments")
+	}
+	if DetectPMTUBlackhole([]Segment{small(1), large(537), large(537), small(1997)}) {
+		t.Error("detected with a single retransmission")
+	}
+}
+
+func TestDetectTimestampAnomaly(t *testing.T) {
+
+	ts := func(sport, dport uint16, tsval uint32) *Packet {
+		p := withOptions(t, Option{Kind: OptionNOP}, Option{Kind: OptionNOP},
+			Option{Kind: OptionTimestamp, Data: []byte{byte(tsval >> 24), byte(tsval >> 16), byte(tsval >> 8), byte(tsval), 0, 0, 0, 0}})
+		binary.BigEndian.PutUint16(p.Header[0:2], sport)
+		binary.BigEndian.PutUint16(p.Header[2:4], dport)
+		return p
+	}
+
+	rising := []*Packet{ts(46926, 443, 100), ts(443, 46926, 5), ts(46926, 443, 100), ts(46926, 443, 150)}
+	if DetectTimestampAnomaly(rising) {
+		t.Error("rising TSvals reported")
+	}
+	if !DetectTimestampAnomaly(append(rising, ts(46926, 443, 120))) {
+		t.Error("TSval regression from 150 to 120 not reported")
+	}
+	if DetectTimestampAnomaly([]*Packet{ts(46926, 443, 0xfffffff0), ts(46926, 443, 16)}) {
+		t.Error("TSval wrapping forward reported")
+	}
+}
+
+func TestIsExactDuplicate(t *testing.T) {
+
+	a := buildPacket(46926, 443, 1001, 5001, FlagPSH|FlagACK, 64240)
+	b := buildPacket(46926, 443, 1001, 5101, FlagPSH|FlagACK, 501)
+	if !IsExactDuplicate(a, b, []byte("hello"), []byte("hello")) {
+		t.Error("same segment resent with a newer ACK and window not reported")
+	}
+	if IsExactDuplicate(a, b, []byte("hello"), []byte("hellO")) {
+		t.Error("retransmission with different bytes reported")
+	}
+	if IsExactDuplicate(a, b, []byte("hello"), []byte("hel")) {
+		t.Error("retransmission resegmented shorter reported")
+	}
+	fin := buildPacket(46926, 443, 1001, 5001, FlagFIN|FlagACK, 64240)
+	if IsExactDuplicate(a, fin, []byte("hello"), []byte("hello")) {
+		t.Error("retransmission adding FIN reported")
+	}
+}
+
+func TestDetectDelayedAck(t *testing.T) {
+
+	t0 := time.Unix(1700000000, 0)
+	ms := func(ns ...int) []time.Time {
+		ts := make([]time.Time, len(ns))
+		for i, n := range ns {
+			ts[i] = t0.Add(time.Duration(n) * time.Millisecond)
+		}
+		return ts
+	}
+
+	data := ms(0, 300, 600, 900)
+	if !DetectDelayedAck(data, ms(40, 340, 601, 940), 40*time.Millisecond) {
+		t.Error("three of four ACKs 40ms late not reported")
+	}
+	if DetectDelayedAck(data, ms(1, 301, 601, 940), 40*time.Millisecond) {
+		t.Error("one late ACK in four reported")
+	}
+	if DetectDelayedAck(data, nil, 40*time.Millisecond) {
+		t.Error("no ACKs reported")
+	}
+}
+
+func TestSegmentCount(t *testing.T) {
+
+	tests := []struct {
+		payloadLen int
+		mss        uint16
+		want       int
+	}{
+		{3000, 1460, 3},
+		{2920, 1460, 2},
+		{1, 1460, 1},
+		{0, 1460, 0},
+		{3000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := SegmentCount(tt.payloadLen, tt.mss); got != tt.want {
+			t.Errorf("SegmentCount(%d, %d) = %d, want %d", tt.payloadLen, tt.mss, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+// Weights AnomalyScore gives each oddity. Forged or scan-like traits weigh
+// more than ones a buggy but honest stack might show.
+const (
+	WeightIllegalFlags    = 5
+	WeightOutOfWindowRST  = 4
+	WeightExcessiveNOPs   = 3
+	WeightTSvalRegression = 3
+	WeightReservedBits    = 2
+)
+
+// FlowContext is what AnomalyScore knows about the packet's direction of
+// flow. Checks whose Have field is false are skipped.
+type FlowContext struct {
+	// RcvNxt and RcvWnd are the receiver's next expected sequence number
+	// and window, in bytes, for the RST window check.
+	RcvNxt  uint32
+	RcvWnd  uint32
+	HaveRcv bool
+
+	// PrevTSval is the sender's last TSval.
+	PrevTSval     uint32
+	HavePrevTSval bool
+}
+
+// AnomalyScore sums the weights of the oddities p shows: an illegal flag
+// combination, nonzero reserved bits, a RST outside the receive window,
+// excessive NOP padding and a TSval that went backwards. A normal packet
+// scores zero.
+func (p *Packet) AnomalyScore(ctx FlowContext) int {
+
+	score := 0
+	if p.illegalFlags() {
+		score += WeightIllegalFlags
+	}
+	if reserved, _ := p.ReservedAndNS(); reserved != 0 {
+		score += WeightReservedBits
+	}
+	if ctx.HaveRcv && p.hasFlag(FlagRST) {
+		// With a zero window only RcvNxt itself is acceptable.
+		seq := p.SequenceNumber()
+		if seq != ctx.RcvNxt && !seqInWindow(seq, ctx.RcvNxt, ctx.RcvWnd) {
+			score += WeightOutOfWindowRST
+		}
+	}
+	if p.HasExcessiveNOPs() {
+		score += WeightExcessiveNOPs
+	}
+	if tsval, _, ok := p.Timestamps(); ok && ctx.HavePrevTSval && seqLT(tsval, ctx.PrevTSval) {
+		score += WeightTSvalRegression
+	}
+	return score
+}
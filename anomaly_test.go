@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestAnomalyScore(t *testing.T) {
+
+	normal := withOptions(t, linuxSYNOptions(1460)...)
+	ctx := FlowContext{PrevTSval: 1, HavePrevTSval: true}
+	if got := normal.AnomalyScore(ctx); got != 0 {
+		t.Errorf("Linux SYN: AnomalyScore = %d, want 0", got)
+	}
+
+	nops := make([]Option, 12)
+	for i := range nops {
+		nops[i].Kind = OptionNOP
+	}
+	weird := withOptions(t, append(nops, Option{Kind: OptionTimestamp, Data: []byte{0, 0, 0, 5, 0, 0, 0, 0}})...)
+	weird.Header[12] |= 0x0e
+	weird.Header[13] = byte(FlagSYN | FlagFIN)
+	want := WeightIllegalFlags + WeightReservedBits + WeightExcessiveNOPs + WeightTSvalRegression
+	if got := weird.AnomalyScore(FlowContext{PrevTSval: 100, HavePrevTSval: true}); got != want {
+		t.Errorf("SYN-FIN with reserved bits, NOP run and old TSval: AnomalyScore = %d, want %d", got, want)
+	}
+
+	rst := buildPacket(46926, 443, 900000, 0, FlagRST, 0)
+	rcv := FlowContext{RcvNxt: 1000, RcvWnd: 65535, HaveRcv: true}
+	if got := rst.AnomalyScore(rcv); got != WeightOutOfWindowRST {
+		t.Errorf("out-of-window RST: AnomalyScore = %d, want %d", got, WeightOutOfWindowRST)
+	}
+	if got := buildPacket(46926, 443, 1000, 0, FlagRST, 0).AnomalyScore(rcv); got != 0 {
+		t.Errorf("RST at RcvNxt: AnomalyScore = %d, want 0", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DefaultBuildWindow is the window advertised by the packet builders.
+const DefaultBuildWindow uint16 = 65535
+
+// buildPacket returns an option-less header with the given fields set. The
+// checksum is left zero since it depends on the IP addresses.
+func buildPacket(src, dst uint16, seq, ack uint32, flags, window uint16) *Packet {
+
+	h := make([]byte, 20)
+	binary.BigEndian.PutUint16(h[0:2], src)
+	binary.BigEndian.PutUint16(h[2:4], dst)
+	binary.BigEndian.PutUint32(h[4:8], seq)
+	binary.BigEndian.PutUint32(h[8:12], ack)
+	h[12] = 5<<4 | byte(flags>>8&0x01)
+	h[13] = byte(flags)
+	binary.BigEndian.PutUint16(h[14:16], window)
+	return &Packet{Header: h}
+}
+
+// BuildHandshake returns the SYN, SYN-ACK and ACK of a three-way handshake.
+// Each side acknowledges the other's ISN plus one, and the final ACK carries
+// the client's ISN plus one as its sequence number.
+func BuildHandshake(clientPort, serverPort uint16, clientISN, serverISN uint32) [3]*Packet {
+
+	return [3]*Packet{
+		buildPacket(clientPort, serverPort, clientISN, 0, FlagSYN, DefaultBuildWindow),
+		buildPacket(serverPort, clientPort, serverISN, clientISN+1, FlagSYN|FlagACK, DefaultBuildWindow),
+		buildPacket(clientPort, serverPort, clientISN+1, serverISN+1, FlagACK, DefaultBuildWindow),
+	}
+}
+
+// BuildAck returns the pure ACK a receiver sends for received: ports
+// swapped, acknowledging received.NextSeq(receivedPayloadLen) and
+// advertising myWindow. Its sequence number is the received segment's
+// acknowledgment number, the next byte the receiver itself would send.
+func BuildAck(received *Packet, receivedPayloadLen uint16, myWindow uint16) *Packet {
+
+	return buildPacket(received.DestinationPort(), received.SourcePort(),
+		received.AckNumber(), received.NextSeq(int(receivedPayloadLen)), FlagACK, myWindow)
+}
+
+// MinimalHeader returns a 20-byte header with the given ports and flags
+// (Flag* bits, NS included), a data offset of 5 and every other field zero.
+func MinimalHeader(src, dst uint16, flags uint16) []byte {
+
+	return buildPacket(src, dst, 0, 0, flags, 0).Header
+}
+
+var (
+	// ErrOffsetMismatch is returned when a Header's data offset disagrees
+	// with the length of its options.
+	ErrOffsetMismatch = errors.New("tcp: data offset does not match options")
+	// ErrIllegalFlags is returned for a flag combination no stack sends.
+	ErrIllegalFlags = errors.New("tcp: illegal flag combination")
+	// ErrStrayUrgent is returned for a nonzero urgent pointer without URG.
+	ErrStrayUrgent = errors.New("tcp: urgent pointer set without URG")
+)
+
+// Header the fields of a header being crafted. Flags holds Flag* bits. A
+// DataOffset of zero stands for "derive it from Options".
+type Header struct {
+	SourcePort      uint16
+	DestinationPort uint16
+	SequenceNumber  uint32
+	AckNumber       uint32
+	DataOffset      uint8
+	Flags           uint16
+	Window          uint16
+	Checksum        uint16
+	UrgentPointer   uint16
+	Options         []Option
+}
+
+// Consistency checks a crafted header before it is serialized and returns
+// the first problem: options that do not encode or do not match a set
+// DataOffset, an illegal flag combination, or an urgent pointer without URG.
+func (h *Header) Consistency() error {
+
+	opts, err := MarshalOptions(h.Options)
+	if err != nil {
+		return err
+	}
+	if h.DataOffset != 0 && int(h.DataOffset)*4 != 20+len(opts) {
+		return fmt.Errorf("%w: offset %d words, %d bytes of options", ErrOffsetMismatch, h.DataOffset, len(opts))
+	}
+	if illegalFlagBits(h.Flags) {
+		return ErrIllegalFlags
+	}
+	if h.UrgentPointer != 0 && h.Flags&FlagURG == 0 {
+		return ErrStrayUrgent
+	}
+	return nil
+}
+
+// Finalize is the last step of crafting: it sets DataOffset from the
+// options, runs Consistency, computes the checksum for srcIP and dstIP and
+// returns the header followed by payload, ready to send. DataOffset and
+// Checksum are updated in h to match the returned bytes.
+func (h *Header) Finalize(srcIP, dstIP net.IP, payload []byte) ([]byte, error) {
+
+	opts, err := MarshalOptions(h.Options)
+	if err != nil {
+		return nil, err
+	}
+	h.DataOffset = uint8(5 + len(opts)/4)
+	if err := h.Consistency(); err != nil {
+		return nil, err
+	}
+
+	p := buildPacket(h.SourcePort, h.DestinationPort, h.SequenceNumber, h.AckNumber, h.Flags, h.Window)
+	binary.BigEndian.PutUint16(p.Header[18:20], h.UrgentPointer)
+	p.Header = append(p.Header, opts...)
+	if err := p.SetDataOffset(h.DataOffset); err != nil {
+		return nil, err
+	}
+
+	h.Checksum = Segment{Packet: p, Payload: payload}.ComputeChecksum(srcIP, dstIP)
+	binary.BigEndian.PutUint16(p.Header[16:18], h.Checksum)
+
+	return append(p.Header, payload...), nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildHandshake(t *testing.T) {
+
+	hs := BuildHandshake(46926, 443, 1000, 5000)
+	syn, synAck, ack := hs[0], hs[1], hs[2]
+
+	if !syn.IsInitialSYN() || syn.SequenceNumber() != 1000 || syn.SourcePort() != 46926 || syn.DestinationPort() != 443 {
+		t.Errorf("SYN = %v", syn)
+	}
+	if synAck.flagBits() != FlagSYN|FlagACK || synAck.SequenceNumber() != 5000 || synAck.AckNumber() != syn.ExpectedAck(0) {
+		t.Errorf("SYN-ACK = %v, want seq 5000 ack %d", synAck, syn.ExpectedAck(0))
+	}
+	if synAck.SourcePort() != 443 || synAck.DestinationPort() != 46926 {
+		t.Errorf("SYN-ACK ports = %d > %d, want 443 > 46926", synAck.SourcePort(), synAck.DestinationPort())
+	}
+	if ack.flagBits() != FlagACK || ack.SequenceNumber() != 1001 || ack.AckNumber() != synAck.ExpectedAck(0) {
+		t.Errorf("ACK = %v, want seq 1001 ack %d", ack, synAck.ExpectedAck(0))
+	}
+	for i, p := range hs {
+		if err := p.ParseCheck(); err != nil {
+			t.Errorf("packet %d: ParseCheck() = %v", i, err)
+		}
+	}
+}
+
+func TestBuildAck(t *testing.T) {
+
+	data := buildPacket(46926, 443, 1001, 5001, FlagPSH|FlagACK, 64240)
+	ack := BuildAck(data, 100, 32768)
+	if ack.flagBits() != FlagACK {
+		t.Errorf("flags = %s, want a pure ACK", ack.tcpdumpFlags())
+	}
+	if ack.AckNumber() != 1101 || ack.SequenceNumber() != 5001 || ack.Window() != 32768 {
+		t.Errorf("ACK = %v, want seq 5001 ack 1101 win 32768", ack)
+	}
+	if ack.SourcePort() != 443 || ack.DestinationPort() != 46926 {
+		t.Errorf("ports = %d > %d, want 443 > 46926", ack.SourcePort(), ack.DestinationPort())
+	}
+
+	fin := buildPacket(46926, 443, 1101, 5001, FlagFIN|FlagACK, 64240)
+	if got := BuildAck(fin, 0, 32768).AckNumber(); got != 1102 {
+		t.Errorf("ACK of a FIN acknowledges %d, want 1102", got)
+	}
+}
+
+func TestMinimalHeader(t *testing.T) {
+
+	p, err := NewPacket(MinimalHeader(46926, 443, FlagSYN))
+	if err != nil {
+		t.Fatalf("NewPacket: %v", err)
+	}
+	if p.SourcePort() != 46926 || p.DestinationPort() != 443 || !p.IsInitialSYN() || p.DO() != 5 {
+		t.Errorf("MinimalHeader = %v, DO %d; want a bare SYN 46926 > 443 with DO 5", p, p.DO())
+	}
+	if p.SequenceNumber() != 0 || p.Window() != 0 || p.Checksum() != 0 {
+		t.Errorf("MinimalHeader = %v, want other fields zero", p)
+	}
+	if _, ns := (&Packet{Header: MinimalHeader(1, 2, FlagNS|FlagACK)}).ReservedAndNS(); !ns {
+		t.Error("NS flag not written")
+	}
+}
+
+func TestHeaderConsistency(t *testing.T) {
+
+	h := Header{SourcePort: 46926, DestinationPort: 443, Flags: FlagSYN, Options: linuxSYNOptions(1460)}
+	if err := h.Consistency(); err != nil {
+		t.Errorf("derived offset: Consistency() = %v", err)
+	}
+	h.DataOffset = 10
+	if err := h.Consistency(); err != nil {
+		t.Errorf("offset 10 with 20 bytes of options: Consistency() = %v", err)
+	}
+
+	h.DataOffset = 8
+	if err := h.Consistency(); !errors.Is(err, ErrOffsetMismatch) {
+		t.Errorf("offset 8 with 20 bytes of options: err = %v, want ErrOffsetMismatch", err)
+	}
+
+	h.DataOffset = 0
+	h.Flags = FlagSYN | FlagFIN
+	if err := h.Consistency(); err != ErrIllegalFlags {
+		t.Errorf("SYN-FIN: err = %v, want ErrIllegalFlags", err)
+	}
+	h.Flags = FlagACK
+	h.UrgentPointer = 10
+	if err := h.Consistency(); err != ErrStrayUrgent {
+		t.Errorf("urgent pointer without URG: err = %v, want ErrStrayUrgent", err)
+	}
+}
+
+func TestHeaderFinalize(t *testing.T) {
+
+	h := Header{
+		SourcePort:      46926,
+		DestinationPort: 443,
+		SequenceNumber:  2974196833,
+		Flags:           FlagSYN,
+		Window:          64240,
+		Options:         linuxSYNOptions(1460),
+	}
+	b, err := h.Finalize(testSrcIP, testDstIP, nil)
+	if err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+	if len(b) != 40 || h.DataOffset != 10 {
+		t.Fatalf("got %d bytes with DataOffset %d, want 40 and 10", len(b), h.DataOffset)
+	}
+
+	p := &Packet{Header: b}
+	if p.DO() != 10 {
+		t.Errorf("DO() = %d, want 10", p.DO())
+	}
+	if h.Checksum != 0x2a5a || p.Checksum() != 0x2a5a {
+		t.Errorf("checksum = %#04x in h, %#04x on the wire; want 0x2a5a", h.Checksum, p.Checksum())
+	}
+	if !(Segment{Packet: p}).VerifyChecksum(testSrcIP, testDstIP) {
+		t.Error("finalized header fails VerifyChecksum")
+	}
+
+	withData, err := h.Finalize(testSrcIP, testDstIP, []byte("hello"))
+	if err != nil || string(withData[40:]) != "hello" {
+		t.Fatalf("with payload: Finalize = %q, %v", withData, err)
+	}
+	if !(Segment{Packet: &Packet{Header: withData[:40]}, Payload: withData[40:]}).VerifyChecksum(testSrcIP, testDstIP) {
+		t.Error("finalized header with payload fails VerifyChecksum")
+	}
+
+	h.Flags = FlagSYN | FlagRST
+	if _, err := h.Finalize(testSrcIP, testDstIP, nil); err != ErrIllegalFlags {
+		t.Errorf("SYN-RST: err = %v, want ErrIllegalFlags", err)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+)
+
+// Segment a TCP header together with the payload it carries.
+type Segment struct {
+	Packet  *Packet
+	Payload []byte
+}
+
+// headerBytes returns the header as given by the data offset, clamped to
+// what is actually present.
+func (p *Packet) headerBytes() []byte {
+
+	n := p.headerLen()
+	if n > len(p.Header) {
+		n = len(p.Header)
+	}
+	return p.Header[:n]
+}
+
+// TCPLength is the TCP length field of the pseudo-header: header plus payload.
+func (p *Packet) TCPLength(payloadLen int) uint16 {
+
+	return uint16(len(p.headerBytes()) + payloadLen)
+}
+
+// ChecksumCoverage returns how many bytes of TCP header and payload the
+// checksum covers; it is TCPLength as an int.
+func (p *Packet) ChecksumCoverage(payloadLen int) int {
+
+	return int(p.TCPLength(payloadLen))
+}
+
+// onesSum adds b to sum as a sequence of big-endian 16-bit words.
+func onesSum(sum uint32, b []byte) uint32 {
+
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// fold reduces a 32-bit one's-complement sum to 16 bits.
+func fold(sum uint32) uint16 {
+
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return uint16(sum)
+}
+
+// pseudoHeaderSum sums the IPv4 or IPv6 pseudo-header. Both addresses must
+// be IPv4 for the IPv4 form to be used.
+func pseudoHeaderSum(srcIP, dstIP net.IP, length int) uint32 {
+
+	var sum uint32
+	if s4, d4 := srcIP.To4(), dstIP.To4(); s4 != nil && d4 != nil {
+		sum = onesSum(sum, s4)
+		sum = onesSum(sum, d4)
+	} else {
+		sum = onesSum(sum, srcIP.To16())
+		sum = onesSum(sum, dstIP.To16())
+		sum += uint32(length >> 16)
+	}
+	sum += 6 // protocol number
+	sum += uint32(length & 0xffff)
+	return sum
+}
+
+// onesSumHeader adds the header bytes to sum with the checksum field taken
+// as zero. Bytes missing from a truncated header are taken as zero too.
+func onesSumHeader(sum uint32, h []byte) uint32 {
+
+	if len(h) <= 16 {
+		return onesSum(sum, h)
+	}
+	sum = onesSum(sum, h[:16])
+	if len(h) > 18 {
+		sum = onesSum(sum, h[18:])
+	}
+	return sum
+}
+
+// ComputeChecksum returns the checksum the segment should carry, treating
+// its checksum field as zero.
+func (s Segment) ComputeChecksum(srcIP, dstIP net.IP) uint16 {
+
+	h := s.Packet.headerBytes()
+	sum := pseudoHeaderSum(srcIP, dstIP, len(h)+len(s.Payload))
+	sum = onesSumHeader(sum, h)
+	sum = onesSum(sum, s.Payload)
+	return ^fold(sum)
+}
+
+// VerifyChecksum reports whether the checksum field matches the segment.
+// A missing packet or one that fails Validate is reported invalid.
+func (s Segment) VerifyChecksum(srcIP, dstIP net.IP) bool {
+
+	if s.Packet == nil || s.Packet.Validate() != nil {
+		return false
+	}
+	return s.ComputeChecksum(srcIP, dstIP) == s.Packet.Checksum()
+}
+
+// VerifyChecksums verifies segments[i] against ips[i] (source, destination)
+// on a pool of workers. Results are in input order; a segment with no
+// matching ips entry, or with a malformed header, is reported invalid.
+func VerifyChecksums(segments []Segment, ips [][2]net.IP, workers int) []bool {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]bool, len(segments))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if i < len(ips) {
+					results[i] = segments[i].VerifyChecksum(ips[i][0], ips[i][1])
+				}
+			}
+		}()
+	}
+	for i := range segments {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// NATCandidate a guess at a segment's addresses and ports before a NAT
+// rewrote them. A nil IP or zero port stands for the packet's own value.
+type NATCandidate struct {
+	SrcIP   net.IP
+	SrcPort uint16
+	DstIP   net.IP
+	DstPort uint16
+}
+
+// LikelyNATModified reports whether the checksum is bad but matches the
+// segment as it was before one of candidates was rewritten into it, which
+// points to a NAT that did not update the checksum. The search is bounded
+// to the candidates, typically the inside addresses and ports a NAT is
+// known to map: a one's-complement checksum absorbs any 16-bit change, so
+// an open search over ports or addresses would explain random corruption
+// as readily as a rewrite, which is why candidates must be given. With none
+// it returns false.
+func (p *Packet) LikelyNATModified(srcIP, dstIP net.IP, payload []byte, candidates []NATCandidate) bool {
+
+	if p.Validate() != nil {
+		return false
+	}
+	stored := p.Checksum()
+	if (Segment{Packet: p, Payload: payload}).ComputeChecksum(srcIP, dstIP) == stored {
+		return false
+	}
+
+	orig := &Packet{Header: append([]byte(nil), p.headerBytes()...)}
+	for _, c := range candidates {
+		src, dst := srcIP, dstIP
+		if c.SrcIP != nil {
+			src = c.SrcIP
+		}
+		if c.DstIP != nil {
+			dst = c.DstIP
+		}
+		sport, dport := p.SourcePort(), p.DestinationPort()
+		if c.SrcPort != 0 {
+			sport = c.SrcPort
+		}
+		if c.DstPort != 0 {
+			dport = c.DstPort
+		}
+		binary.BigEndian.PutUint16(orig.Header[0:2], sport)
+		binary.BigEndian.PutUint16(orig.Header[2:4], dport)
+		if (Segment{Packet: orig, Payload: payload}).ComputeChecksum(src, dst) == stored {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderOnlyChecksum returns the checksum of the header bytes alone, with
+// the checksum field taken as zero and no pseudo-header or payload. It is
+// not a valid wire checksum; comparing it between two copies of a packet
+// tells whether a discrepancy lies in the header or elsewhere. Only the
+// bytes the data offset covers are summed, however few.
+func (p *Packet) HeaderOnlyChecksum() uint16 {
+
+	return ^fold(onesSumHeader(0, p.headerBytes()))
+}
+
+// rollingBase is the multiplier of PayloadRollingHash.
+const rollingBase = 257
+
+// PayloadRollingHash returns the Rabin-Karp hash, base 257 modulo 2^32, of
+// every windowSize-byte window of payload: element i covers
+// payload[i:i+windowSize]. Chunk boundaries for deduplication are usually
+// cut where the hash matches a bit mask. It returns nil when windowSize is
+// not between 1 and len(payload).
+func PayloadRollingHash(payload []byte, windowSize int) []uint32 {
+
+	if windowSize < 1 || windowSize > len(payload) {
+		return nil
+	}
+
+	// out is rollingBase^(windowSize-1), the weight of the byte leaving the window.
+	var h, out uint32 = 0, 1
+	for i := 0; i < windowSize; i++ {
+		h = h*rollingBase + uint32(payload[i])
+		if i > 0 {
+			out *= rollingBase
+		}
+	}
+
+	hashes := make([]uint32, 0, len(payload)-windowSize+1)
+	hashes = append(hashes, h)
+	for i := windowSize; i < len(payload); i++ {
+		h = (h-uint32(payload[i-windowSize])*out)*rollingBase + uint32(payload[i])
+		hashes = append(hashes, h)
+	}
+	return hashes
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+var (
+	testSrcIP = net.ParseIP("10.0.0.1")
+	testDstIP = net.ParseIP("10.0.0.2")
+)
+
+// checksummed writes the correct checksum for payload into p and returns
+// the segment.
+func checksummed(p *Packet, payload []byte) Segment {
+
+	s := Segment{Packet: p, Payload: payload}
+	binary.BigEndian.PutUint16(p.Header[16:18], s.ComputeChecksum(testSrcIP, testDstIP))
+	return s
+}
+
+func TestVerifyChecksums(t *testing.T) {
+
+	valid := checksummed(buildPacket(46926, 443, 1, 1, FlagPSH|FlagACK, 64240), []byte("hello"))
+	corrupt := checksummed(buildPacket(46926, 443, 2, 1, FlagPSH|FlagACK, 64240), []byte("hello"))
+	corrupt.Payload = []byte("jello")
+	lowOffset := checksummed(buildPacket(46926, 443, 3, 1, FlagACK, 64240), nil)
+	lowOffset.Packet.Header[12] = 2 << 4
+	truncated := Segment{Packet: &Packet{Header: sampleHeader()[:10]}}
+	noPacket := Segment{}
+
+	segments := []Segment{valid, corrupt, lowOffset, valid, truncated, noPacket, valid}
+	want := []bool{true, false, false, true, false, false, false}
+	ips := make([][2]net.IP, len(segments)-1) // the last segment has no ips entry
+	for i := range ips {
+		ips[i] = [2]net.IP{testSrcIP, testDstIP}
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		got := VerifyChecksums(segments, ips, workers)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("workers=%d: result %d = %v, want %v", workers, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestChecksumCoverage(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1, 1, FlagACK, 64240)
+	if got := p.ChecksumCoverage(100); got != 120 {
+		t.Errorf("ChecksumCoverage(100) = %d, want 120", got)
+	}
+	p = withOptions(t, linuxSYNOptions(1460)...)
+	if got := p.ChecksumCoverage(0); got != 40 {
+		t.Errorf("SYN with 20 bytes of options: ChecksumCoverage(0) = %d, want 40", got)
+	}
+}
+
+func TestLikelyNATModified(t *testing.T) {
+
+	inside := net.ParseIP("192.168.1.10")
+	outside := net.ParseIP("203.0.113.5")
+	payload := []byte("GET / HTTP/1.1\r\n\r\n")
+
+	// Checksummed by the client for inside:40000, then rewritten by a NAT
+	// to outside:61000 without updating the checksum.
+	p := buildPacket(40000, 80, 1001, 5001, FlagPSH|FlagACK, 64240)
+	s := Segment{Packet: p, Payload: payload}
+	binary.BigEndian.PutUint16(p.Header[16:18], s.ComputeChecksum(inside, testDstIP))
+	binary.BigEndian.PutUint16(p.Header[0:2], 61000)
+
+	candidates := []NATCandidate{
+		{SrcIP: net.ParseIP("192.168.1.11"), SrcPort: 40000},
+		{SrcIP: inside, SrcPort: 40000},
+	}
+	if !p.LikelyNATModified(outside, testDstIP, payload, candidates) {
+		t.Error("rewrite from a candidate not detected")
+	}
+	if p.LikelyNATModified(outside, testDstIP, payload, candidates[:1]) {
+		t.Error("detected with only a wrong candidate")
+	}
+	if p.LikelyNATModified(outside, testDstIP, payload, nil) {
+		t.Error("detected without candidates")
+	}
+
+	// A port-only rewrite, the addresses left alone.
+	q := buildPacket(40000, 80, 1001, 5001, FlagPSH|FlagACK, 64240)
+	checksummed(q, payload)
+	binary.BigEndian.PutUint16(q.Header[0:2], 61000)
+	if !q.LikelyNATModified(testSrcIP, testDstIP, payload, []NATCandidate{{SrcPort: 40000}}) {
+		t.Error("port-only rewrite not detected")
+	}
+
+	valid := checksummed(buildPacket(40000, 80, 1001, 5001, FlagACK, 64240), payload)
+	if valid.Packet.LikelyNATModified(testSrcIP, testDstIP, payload, []NATCandidate{{SrcPort: 40000}}) {
+		t.Error("valid checksum reported as NAT-modified")
+	}
+}
+
+func TestLikelyNATModifiedRandomCorruption(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	payload := []byte("some payload")
+	candidates := []NATCandidate{
+		{SrcPort: 40000},
+		{SrcIP: net.ParseIP("192.168.1.10"), SrcPort: 40000},
+		{DstIP: net.ParseIP("192.168.1.20"), DstPort: 8080},
+	}
+
+	hits := 0
+	for i := 0; i < 10000; i++ {
+		s := checksummed(buildPacket(40000, 443, rng.Uint32(), rng.Uint32(), FlagACK, uint16(rng.Intn(65536))), payload)
+		// Damage one byte of seq, ack or window; the ports are left alone.
+		off := []int{4, 5, 6, 7, 8, 9, 10, 11, 14, 15}[rng.Intn(10)]
+		s.Packet.Header[off] ^= byte(1 + rng.Intn(255))
+		if s.Packet.LikelyNATModified(testSrcIP, testDstIP, payload, candidates) {
+			hits++
+		}
+	}
+	// Each candidate matches damage by chance about once in 65536 tries.
+	if hits > 5 {
+		t.Errorf("random corruption reported as NAT %d times in 10000", hits)
+	}
+}
+
+func TestHeaderOnlyChecksum(t *testing.T) {
+
+	p := &Packet{Header: sampleHeader()}
+	if got := p.HeaderOnlyChecksum(); got != 0x565a {
+		t.Errorf("HeaderOnlyChecksum() = %#04x, want 0x565a", got)
+	}
+	p.Header[16], p.Header[17] = 0x12, 0x34
+	if got := p.HeaderOnlyChecksum(); got != 0x565a {
+		t.Errorf("with another checksum field: HeaderOnlyChecksum() = %#04x, want 0x565a", got)
+	}
+
+	for _, words := range []byte{0, 2, 4} {
+		p.Header[12] = words << 4
+		_ = p.HeaderOnlyChecksum() // must not panic
+	}
+}
+
+func TestPayloadRollingHash(t *testing.T) {
+
+	payload := []byte("abcdefghij")
+	got := PayloadRollingHash(payload, 4)
+	if len(got) != 7 {
+		t.Fatalf("got %d hashes, want 7", len(got))
+	}
+	if got[0] != 1653033866 {
+		t.Errorf("hash of %q = %d, want 1653033866", "abcd", got[0])
+	}
+	for i := range got {
+		var want uint32
+		for _, c := range payload[i : i+4] {
+			want = want*rollingBase + uint32(c)
+		}
+		if got[i] != want {
+			t.Errorf("window %d: rolled hash %d, recomputed %d", i, got[i], want)
+		}
+	}
+
+	if PayloadRollingHash(payload, 0) != nil || PayloadRollingHash(payload, 11) != nil {
+		t.Error("out-of-range window size returned hashes")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrBadCompact is returned by UnmarshalCompact for malformed input.
+var ErrBadCompact = errors.New("tcp: malformed compact encoding")
+
+// MarshalCompact encodes the fixed header fields as varints, with byte 13 as
+// a single flags byte. Relative sequence numbers and small ports make this
+// much shorter than 20 bytes. Options, the reserved bits and NS are dropped.
+func (p *Packet) MarshalCompact() []byte {
+
+	b := make([]byte, 0, 2*binary.MaxVarintLen32+1+4*binary.MaxVarintLen16)
+	b = appendUvarint(b, uint64(p.SourcePort()))
+	b = appendUvarint(b, uint64(p.DestinationPort()))
+	b = appendUvarint(b, uint64(p.SequenceNumber()))
+	b = appendUvarint(b, uint64(p.AckNumber()))
+	b = append(b, byte(p.flagBits()))
+	b = appendUvarint(b, uint64(p.Window()))
+	b = appendUvarint(b, uint64(p.Checksum()))
+	b = appendUvarint(b, uint64(p.UrgentPointer()))
+	return b
+}
+
+// UnmarshalCompact decodes the output of MarshalCompact into a 20-byte
+// header with a data offset of 5.
+func UnmarshalCompact(b []byte) (*Packet, error) {
+
+	h := make([]byte, 20)
+	h[12] = 5 << 4
+
+	var v uint64
+	var err error
+	if v, b, err = readUvarint(b, 0xffff); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(h[0:2], uint16(v))
+	if v, b, err = readUvarint(b, 0xffff); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(h[2:4], uint16(v))
+	if v, b, err = readUvarint(b, 0xffffffff); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(h[4:8], uint32(v))
+	if v, b, err = readUvarint(b, 0xffffffff); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(h[8:12], uint32(v))
+	if len(b) == 0 {
+		return nil, ErrBadCompact
+	}
+	h[13], b = b[0], b[1:]
+	for _, off := range []int{14, 16, 18} {
+		if v, b, err = readUvarint(b, 0xffff); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(h[off:off+2], uint16(v))
+	}
+	if len(b) != 0 {
+		return nil, ErrBadCompact
+	}
+
+	return &Packet{Header: h}, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func readUvarint(b []byte, max uint64) (uint64, []byte, error) {
+
+	v, n := binary.Uvarint(b)
+	if n <= 0 || v > max {
+		return 0, nil, ErrBadCompact
+	}
+	return v, b[n:], nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+
+	p := &Packet{Header: sampleHeader()}
+	p.Header[12] = 5 << 4 // no options to drop
+	b := p.MarshalCompact()
+	if len(b) >= 20 {
+		t.Errorf("compact encoding is %d bytes, want fewer than 20", len(b))
+	}
+
+	q, err := UnmarshalCompact(b)
+	if err != nil {
+		t.Fatalf("UnmarshalCompact: %v", err)
+	}
+	if !bytes.Equal(q.Header, p.Header) {
+		t.Errorf("round trip = %x, want %x", q.Header, p.Header)
+	}
+
+	if _, err := UnmarshalCompact(b[:len(b)-1]); !errors.Is(err, ErrBadCompact) {
+		t.Errorf("truncated input: err = %v, want ErrBadCompact", err)
+	}
+	if _, err := UnmarshalCompact(append(b, 0)); !errors.Is(err, ErrBadCompact) {
+		t.Errorf("trailing byte: err = %v, want ErrBadCompact", err)
+	}
+}
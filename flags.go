@@ -0,0 +1,85 @@
+package main
+
+// Control flag bits, as they sit in the low 9 bits of bytes 12-13.
+const (
+	FlagFIN uint16 = 1 << iota
+	FlagSYN
+	FlagRST
+	FlagPSH
+	FlagACK
+	FlagURG
+	FlagECE
+	FlagCWR
+	FlagNS
+)
+
+// flagBits returns the 9 control flag bits (NS through FIN), or zero when
+// the header stops short of them.
+func (p *Packet) flagBits() uint16 {
+
+	if len(p.Header) < 14 {
+		return 0
+	}
+	return uint16(p.Header[12]&0x01)<<8 | uint16(p.Header[13])
+}
+
+// hasFlag reports whether every bit in f is set.
+func (p *Packet) hasFlag(f uint16) bool {
+
+	return p.flagBits()&f == f
+}
+
+// IsControl reports whether the packet sets up or tears down a connection,
+// i.e. SYN, FIN or RST is set.
+func (p *Packet) IsControl() bool {
+
+	return p.flagBits()&(FlagSYN|FlagFIN|FlagRST) != 0
+}
+
+// IsInitialSYN reports whether the packet is the first of a connection:
+// SYN set with ACK, RST and FIN clear. A SYN-ACK is not an initial SYN.
+func (p *Packet) IsInitialSYN() bool {
+
+	return p.flagBits()&(FlagSYN|FlagACK|FlagRST|FlagFIN) == FlagSYN
+}
+
+// flagInfo describes one control flag for rendering.
+type flagInfo struct {
+	bit    uint16
+	name   string
+	letter string
+}
+
+// flagTable lists the flags in the order tcpdump prints them.
+var flagTable = []flagInfo{
+	{FlagFIN, "FIN", "F"},
+	{FlagSYN, "SYN", "S"},
+	{FlagRST, "RST", "R"},
+	{FlagPSH, "PSH", "P"},
+	{FlagACK, "ACK", "."},
+	{FlagURG, "URG", "U"},
+	{FlagECE, "ECE", "E"},
+	{FlagCWR, "CWR", "W"},
+	{FlagNS, "NS", "N"},
+}
+
+// illegalFlags reports flag combinations no conforming stack sends: no flags
+// at all (a NULL scan), SYN with FIN or RST, and FIN, PSH or URG without ACK
+// (FIN and Xmas scans).
+func (p *Packet) illegalFlags() bool {
+
+	return illegalFlagBits(p.flagBits())
+}
+
+func illegalFlagBits(f uint16) bool {
+
+	switch {
+	case f&^FlagNS == 0:
+		return true
+	case f&FlagSYN != 0 && f&(FlagFIN|FlagRST) != 0:
+		return true
+	case f&FlagACK == 0 && f&(FlagFIN|FlagPSH|FlagURG) != 0:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestIsControl(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		flags uint16
+		want  bool
+	}{
+		{"SYN", FlagSYN, true},
+		{"FIN-ACK", FlagFIN | FlagACK, true},
+		{"RST", FlagRST, true},
+		{"data ACK", FlagPSH | FlagACK, false},
+	}
+	for _, tt := range tests {
+		p := buildPacket(46926, 443, 1, 1, tt.flags, 64240)
+		if got := p.IsControl(); got != tt.want {
+			t.Errorf("%s: IsControl() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsInitialSYN(t *testing.T) {
+
+	syn := buildPacket(46926, 443, 1, 0, FlagSYN, 64240)
+	if !syn.IsInitialSYN() {
+		t.Error("bare SYN: IsInitialSYN() = false")
+	}
+	synECN := buildPacket(46926, 443, 1, 0, FlagSYN|FlagECE|FlagCWR, 64240)
+	if !synECN.IsInitialSYN() {
+		t.Error("ECN-setup SYN: IsInitialSYN() = false")
+	}
+	synAck := buildPacket(443, 46926, 1, 2, FlagSYN|FlagACK, 64240)
+	if synAck.IsInitialSYN() {
+		t.Error("SYN-ACK: IsInitialSYN() = true")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// endpoint formats an address and port, bracketing IPv6 addresses.
+func endpoint(ip net.IP, port uint16) string {
+
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+// FlowKey returns the key of the packet's direction of flow,
+// e.g. "10.0.0.1:46926->10.0.0.2:443".
+func FlowKey(srcIP, dstIP net.IP, p *Packet) string {
+
+	return endpoint(srcIP, p.SourcePort()) + "->" + endpoint(dstIP, p.DestinationPort())
+}
+
+// FlowKeyPair returns the packet's FlowKey together with the key of the
+// opposite direction.
+func FlowKeyPair(srcIP, dstIP net.IP, p *Packet) (fwd, rev string) {
+
+	src := endpoint(srcIP, p.SourcePort())
+	dst := endpoint(dstIP, p.DestinationPort())
+	return src + "->" + dst, dst + "->" + src
+}
+
+// NetFlowFields returns the fields a NetFlow v5/v9 record takes from the
+// packet, keyed by their v5 names. tcp_flags is the combined flags byte.
+func (p *Packet) NetFlowFields(srcIP, dstIP net.IP) map[string]interface{} {
+
+	return map[string]interface{}{
+		"srcaddr":   srcIP.String(),
+		"dstaddr":   dstIP.String(),
+		"srcport":   p.SourcePort(),
+		"dstport":   p.DestinationPort(),
+		"prot":      uint8(6),
+		"tcp_flags": byte(p.flagBits()),
+	}
+}
+
+// CanonicalFlowKey returns a key shared by both directions of a connection,
+// e.g. "10.0.0.1:46926<->10.0.0.2:443", with the lower endpoint first.
+func CanonicalFlowKey(srcIP, dstIP net.IP, p *Packet) string {
+
+	a, b := endpointOrder(srcIP, p.SourcePort(), dstIP, p.DestinationPort())
+	return a + "<->" + b
+}
+
+// endpointOrder formats two endpoints, lower address (then port) first.
+func endpointOrder(ipA net.IP, portA uint16, ipB net.IP, portB uint16) (string, string) {
+
+	a, b := endpoint(ipA, portA), endpoint(ipB, portB)
+	c := bytes.Compare(ipA.To16(), ipB.To16())
+	if c > 0 || c == 0 && portA > portB {
+		a, b = b, a
+	}
+	return a, b
+}
+
+// GroupByFlow buckets segments by CanonicalFlowKey, so both directions of
+// a connection share a bucket. ips[i] holds the source and destination of
+// segments[i]; segments without an ips entry are skipped.
+func GroupByFlow(segments []Segment, ips [][2]net.IP) map[string][]Segment {
+
+	flows := make(map[string][]Segment)
+	for i, s := range segments {
+		if i >= len(ips) {
+			break
+		}
+		key := CanonicalFlowKey(ips[i][0], ips[i][1], s.Packet)
+		flows[key] = append(flows[key], s)
+	}
+	return flows
+}
+
+// PacketID returns a deterministic UUID-formatted ID for the packet, hashed
+// from its addresses, ports, sequence number, flags and rxTime. Identical
+// inputs always give the same ID, so a packet seen at two taps can be
+// matched when the times are normalised by the caller. The ID is a
+// version 8 (custom) UUID per RFC 9562.
+func (p *Packet) PacketID(srcIP, dstIP net.IP, rxTime time.Time) string {
+
+	var b [16]byte
+	h := sha256.New()
+	h.Write(srcIP.To16())
+	h.Write(dstIP.To16())
+	binary.BigEndian.PutUint16(b[0:2], p.SourcePort())
+	binary.BigEndian.PutUint16(b[2:4], p.DestinationPort())
+	binary.BigEndian.PutUint32(b[4:8], p.SequenceNumber())
+	binary.BigEndian.PutUint16(b[8:10], p.flagBits())
+	h.Write(b[:10])
+	binary.BigEndian.PutUint64(b[0:8], uint64(rxTime.UnixNano()))
+	h.Write(b[:8])
+
+	copy(b[:], h.Sum(nil))
+	b[6] = b[6]&0x0f | 0x80
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// BelongsTo reports whether the packet, sent from srcIP to dstIP, is part
+// of the connection whose CanonicalFlowKey is key, in either direction.
+func (p *Packet) BelongsTo(key string, srcIP, dstIP net.IP) bool {
+
+	return CanonicalFlowKey(srcIP, dstIP, p) == key
+}
+
+// IsSelfConnect reports whether the packet goes from an endpoint to itself:
+// the same address and port on both ends, as in a TCP simultaneous open
+// against its own port.
+func IsSelfConnect(srcIP, dstIP net.IP, p *Packet) bool {
+
+	return srcIP.Equal(dstIP) && p.SourcePort() == p.DestinationPort()
+}
+
+// StableConnID returns an ID for the application connection identified by
+// clientCookie, taken by the caller from early payload, on the service
+// listening on servicePort. Client addresses and ports change under NAT
+// rebinding and nothing in a header tells which end is the service, so the
+// caller names it; the same cookie on the same service always gives the
+// same ID. It returns "" when neither port of p is servicePort.
+func StableConnID(p *Packet, servicePort uint16, clientCookie uint32) string {
+
+	if p.SourcePort() != servicePort && p.DestinationPort() != servicePort {
+		return ""
+	}
+
+	var b [6]byte
+	binary.BigEndian.PutUint16(b[0:2], servicePort)
+	binary.BigEndian.PutUint32(b[2:6], clientCookie)
+	sum := sha256.Sum256(b[:])
+	return fmt.Sprintf("%x", sum[:8])
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlowKeyPair(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1, 0, FlagSYN, 64240)
+	fwd, rev := FlowKeyPair(testSrcIP, testDstIP, p)
+	if want := FlowKey(testSrcIP, testDstIP, p); fwd != want {
+		t.Errorf("fwd = %q, want FlowKey %q", fwd, want)
+	}
+
+	reply := buildPacket(443, 46926, 1, 2, FlagSYN|FlagACK, 64240)
+	if want := FlowKey(testDstIP, testSrcIP, reply); rev != want {
+		t.Errorf("rev = %q, want the reply's FlowKey %q", rev, want)
+	}
+
+	v6 := net.ParseIP("2001:db8::1")
+	fwd, _ = FlowKeyPair(v6, testDstIP, p)
+	if want := "[2001:db8::1]:46926->10.0.0.2:443"; fwd != want {
+		t.Errorf("IPv6 fwd = %q, want %q", fwd, want)
+	}
+}
+
+func TestNetFlowFields(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1, 1, FlagPSH|FlagACK, 64240)
+	f := p.NetFlowFields(testSrcIP, testDstIP)
+	want := map[string]interface{}{
+		"srcaddr":   "10.0.0.1",
+		"dstaddr":   "10.0.0.2",
+		"srcport":   uint16(46926),
+		"dstport":   uint16(443),
+		"prot":      uint8(6),
+		"tcp_flags": uint8(0x18),
+	}
+	for k, v := range want {
+		if f[k] != v {
+			t.Errorf("%s = %v (%T), want %v (%T)", k, f[k], f[k], v, v)
+		}
+	}
+}
+
+func TestGroupByFlow(t *testing.T) {
+
+	client2 := net.ParseIP("10.0.0.3")
+	segments := []Segment{
+		{Packet: buildPacket(46926, 443, 1, 0, FlagSYN, 64240)},
+		{Packet: buildPacket(443, 46926, 1, 2, FlagSYN|FlagACK, 64240)},
+		{Packet: buildPacket(51000, 443, 1, 0, FlagSYN, 64240)},
+	}
+	ips := [][2]net.IP{
+		{testSrcIP, testDstIP},
+		{testDstIP, testSrcIP},
+		{client2, testDstIP},
+	}
+
+	flows := GroupByFlow(segments, ips)
+	if len(flows) != 2 {
+		t.Fatalf("got %d flows, want 2: %v", len(flows), flows)
+	}
+	if got := flows["10.0.0.1:46926<->10.0.0.2:443"]; len(got) != 2 {
+		t.Errorf("first connection has %d segments, want 2", len(got))
+	}
+	if got := flows["10.0.0.2:443<->10.0.0.3:51000"]; len(got) != 1 {
+		t.Errorf("second connection has %d segments, want 1", len(got))
+	}
+}
+
+func TestPacketID(t *testing.T) {
+
+	at := time.Unix(1700000000, 0)
+	a := buildPacket(46926, 443, 1, 0, FlagSYN, 64240)
+	b := buildPacket(46926, 443, 1, 0, FlagSYN, 1024) // window is not hashed
+
+	id := a.PacketID(testSrcIP, testDstIP, at)
+	if got := b.PacketID(testSrcIP, testDstIP, at); got != id {
+		t.Errorf("identical inputs: %s != %s", got, id)
+	}
+	if len(id) != 36 || id[14] != '8' || !strings.ContainsAny(id[19:20], "89ab") {
+		t.Errorf("PacketID = %s, want a version 8 UUID", id)
+	}
+	if got := a.PacketID(testSrcIP, testDstIP, at.Add(time.Nanosecond)); got == id {
+		t.Error("different rxTime gave the same ID")
+	}
+}
+
+func TestBelongsTo(t *testing.T) {
+
+	c, s := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+	out := buildPacket(46926, 443, 1, 1, FlagACK, 64240)
+	key := CanonicalFlowKey(c, s, out)
+
+	back := buildPacket(443, 46926, 1, 1, FlagACK, 64240)
+	if !back.BelongsTo(key, s, c) {
+		t.Error("reply direction does not belong to the flow")
+	}
+	if !out.BelongsTo(key, c, s) {
+		t.Error("original direction does not belong to the flow")
+	}
+
+	other := buildPacket(46927, 443, 1, 1, FlagACK, 64240)
+	if other.BelongsTo(key, c, s) {
+		t.Error("packet from another source port belongs to the flow")
+	}
+	if out.BelongsTo(key, c, net.ParseIP("10.0.0.3")) {
+		t.Error("packet to another server belongs to the flow")
+	}
+}
+
+func TestIsSelfConnect(t *testing.T) {
+
+	lo := net.ParseIP("127.0.0.1")
+	self := buildPacket(40000, 40000, 1, 0, FlagSYN, 64240)
+	if !IsSelfConnect(lo, net.ParseIP("127.0.0.1"), self) {
+		t.Error("127.0.0.1:40000 to itself not reported")
+	}
+	if IsSelfConnect(lo, net.ParseIP("127.0.0.2"), self) {
+		t.Error("same port on another address reported")
+	}
+	if IsSelfConnect(lo, lo, buildPacket(40000, 443, 1, 0, FlagSYN, 64240)) {
+		t.Error("loopback connection to another port reported")
+	}
+}
+
+func TestStableConnID(t *testing.T) {
+
+	// A gRPC service on 50051 whose client rebinds from 40000 to 55000.
+	before := buildPacket(40000, 50051, 1, 1, FlagACK, 64240)
+	rebound := buildPacket(50051, 55000, 9, 9, FlagACK, 65160)
+	id := StableConnID(before, 50051, 0xc0ffee)
+	if got := StableConnID(rebound, 50051, 0xc0ffee); got != id {
+		t.Errorf("same cookie after rebinding: %s, want %s", got, id)
+	}
+	if len(id) != 16 {
+		t.Errorf("ID %q is %d characters, want 16", id, len(id))
+	}
+
+	if StableConnID(before, 50051, 0xc0ffef) == id {
+		t.Error("different cookie gave the same ID")
+	}
+	if StableConnID(buildPacket(40000, 8443, 1, 1, FlagACK, 64240), 8443, 0xc0ffee) == id {
+		t.Error("same cookie on another service gave the same ID")
+	}
+	if got := StableConnID(before, 443, 0xc0ffee); got != "" {
+		t.Errorf("packet not on the service: %q, want empty", got)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NoColor disables the ANSI colors of FlagsColored. It follows the NO_COLOR
+// convention by default and may be set by the caller.
+var NoColor = os.Getenv("NO_COLOR") != ""
+
+// flagColors maps flags to ANSI color codes; unlisted flags are uncolored.
+var flagColors = map[uint16]string{
+	FlagSYN: "32", // green
+	FlagRST: "31", // red
+	FlagFIN: "33", // yellow
+	FlagACK: "34", // blue
+	FlagPSH: "35", // magenta
+	FlagURG: "36", // cyan
+}
+
+// FlagsColored returns the letters of the set flags, tcpdump style, each
+// wrapped in its ANSI color unless NoColor is set.
+func (p *Packet) FlagsColored() string {
+
+	var sb strings.Builder
+	for _, f := range flagTable {
+		if !p.hasFlag(f.bit) {
+			continue
+		}
+		color, ok := flagColors[f.bit]
+		if NoColor || !ok {
+			sb.WriteString(f.letter)
+			continue
+		}
+		sb.WriteString("\x1b[" + color + "m" + f.letter + "\x1b[0m")
+	}
+	return sb.String()
+}
+
+// BPFFilter returns a tcpdump expression matching this packet's direction
+// of flow, e.g. "tcp and src host 1.2.3.4 and src port 46926 and
+// dst host 5.6.7.8 and dst port 443".
+func (p *Packet) BPFFilter(srcIP, dstIP net.IP) string {
+
+	return fmt.Sprintf("tcp and src host %s and src port %d and dst host %s and dst port %d",
+		srcIP, p.SourcePort(), dstIP, p.DestinationPort())
+}
+
+// tcpdumpOptionNames are the names tcpdump gives option kinds.
+var tcpdumpOptionNames = map[uint8]string{
+	OptionEOL:       "eol",
+	OptionNOP:       "nop",
+	OptionMSS:       "mss",
+	OptionWScale:    "wscale",
+	OptionSACKPerm:  "sackOK",
+	OptionSACK:      "sack",
+	OptionTimestamp: "TS",
+	19:              "md5",
+	28:              "uto",
+	29:              "tcp-ao",
+	30:              "mptcp",
+	OptionFastOpen:  "tfo",
+	OptionExp2:      "exp",
+}
+
+// TcpdumpLine renders the packet the way tcpdump -v prints a TCP segment,
+// e.g. "IP 10.0.0.1.46926 > 10.0.0.2.443: Flags [S], cksum 0x2a5a (correct),
+// seq 2974196833, win 64240, options [mss 1460,sackOK,TS val 1 ecr 0,nop,
+// wscale 7], length 0". The IP header fields -v adds after "IP" are not in
+// a TCP header and are left out. Sequence numbers are absolute, as on the
+// first packet of a connection. As tcpdump does when the payload is not in
+// the capture, cksum is only printed for segments without payload, since
+// only the payload length is known here.
+func (p *Packet) TcpdumpLine(srcIP, dstIP net.IP, payloadLen int) string {
+
+	proto := "IP"
+	if srcIP.To4() == nil {
+		proto = "IP6"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s.%d > %s.%d: Flags [%s]", proto, srcIP, p.SourcePort(), dstIP, p.DestinationPort(), p.tcpdumpFlags())
+	if payloadLen == 0 && p.Validate() == nil {
+		stored := p.Checksum()
+		if want := (Segment{Packet: p}).ComputeChecksum(srcIP, dstIP); want == stored {
+			fmt.Fprintf(&sb, ", cksum 0x%04x (correct)", stored)
+		} else {
+			fmt.Fprintf(&sb, ", cksum 0x%04x (incorrect -> 0x%04x)", stored, want)
+		}
+	}
+
+	seq := p.SequenceNumber()
+	if payloadLen > 0 {
+		fmt.Fprintf(&sb, ", seq %d:%d", seq, seq+uint32(payloadLen))
+	} else if p.flagBits()&(FlagSYN|FlagFIN|FlagRST) != 0 {
+		fmt.Fprintf(&sb, ", seq %d", seq)
+	}
+	if p.hasFlag(FlagACK) {
+		fmt.Fprintf(&sb, ", ack %d", p.AckNumber())
+	}
+	fmt.Fprintf(&sb, ", win %d", p.Window())
+	if p.hasFlag(FlagURG) {
+		fmt.Fprintf(&sb, ", urg %d", p.UrgentPointer())
+	}
+	if opts, _, err := p.walkOptions(ParseOptions{}, true); err == nil && len(opts) > 0 {
+		sb.WriteString(", options [")
+		for i, o := range opts {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(tcpdumpOption(o))
+		}
+		sb.WriteString("]")
+	}
+	fmt.Fprintf(&sb, ", length %d", payloadLen)
+
+	return sb.String()
+}
+
+// tcpdumpFlags returns the flag letters as tcpdump prints them.
+func (p *Packet) tcpdumpFlags() string {
+
+	var letters string
+	for _, f := range flagTable {
+		if f.bit != FlagNS && p.hasFlag(f.bit) {
+			letters += f.letter
+		}
+	}
+	if letters == "" {
+		return "none"
+	}
+	return letters
+}
+
+// tcpdumpOption renders one option in tcpdump's notation.
+func tcpdumpOption(o Option) string {
+
+	name, ok := tcpdumpOptionNames[o.Kind]
+	if !ok {
+		name = fmt.Sprintf("unknown-%d", o.Kind)
+	}
+
+	switch {
+	case o.Kind == OptionEOL, o.Kind == OptionNOP, o.Kind == OptionSACKPerm:
+		return name
+	case o.Kind == OptionMSS && len(o.Data) == 2:
+		return fmt.Sprintf("%s %d", name, binary.BigEndian.Uint16(o.Data))
+	case o.Kind == OptionWScale && len(o.Data) == 1:
+		return fmt.Sprintf("%s %d", name, o.Data[0])
+	case o.Kind == OptionTimestamp && len(o.Data) == 8:
+		return fmt.Sprintf("%s val %d ecr %d", name, binary.BigEndian.Uint32(o.Data), binary.BigEndian.Uint32(o.Data[4:]))
+	case o.Kind == OptionSACK:
+		blocks := sackBlocks(o.Data)
+		s := fmt.Sprintf("%s %d ", name, len(blocks))
+		for _, b := range blocks {
+			s += fmt.Sprintf("{%d:%d}", b[0], b[1])
+		}
+		return s
+	case o.Kind == OptionFastOpen && len(o.Data) == 0:
+		return name + " cookiereq"
+	case o.Kind == OptionFastOpen:
+		return fmt.Sprintf("%s cookie %x", name, o.Data)
+	case len(o.Data) == 0:
+		return name
+	}
+	return fmt.Sprintf("%s %x", name, o.Data)
+}
+
+// syslogEscaper escapes PARAM-VALUE characters per RFC 5424 section 6.3.3.
+var syslogEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// SyslogSD returns an RFC 5424 structured-data element describing the packet,
+// e.g. [tcp@32473 src="10.0.0.1" dst="10.0.0.2" sport="46926" dport="443"
+// flags="SYN" seq="2974196833"]. 32473 is the example enterprise number
+// reserved by RFC 5612.
+func (p *Packet) SyslogSD(srcIP, dstIP net.IP) string {
+
+	params := [][2]string{
+		{"src", srcIP.String()},
+		{"dst", dstIP.String()},
+		{"sport", strconv.Itoa(int(p.SourcePort()))},
+		{"dport", strconv.Itoa(int(p.DestinationPort()))},
+		{"flags", p.flagNames(",")},
+		{"seq", strconv.FormatUint(uint64(p.SequenceNumber()), 10)},
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[tcp@32473")
+	for _, kv := range params {
+		fmt.Fprintf(&sb, ` %s="%s"`, kv[0], syslogEscaper.Replace(kv[1]))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// flagNames returns the names of the set flags joined by sep.
+func (p *Packet) flagNames(sep string) string {
+
+	var names []string
+	for _, f := range flagTable {
+		if p.hasFlag(f.bit) {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, sep)
+}
+
+// String renders the packet on one line,
+// e.g. "46926 > 443 [S] seq 2974196833 ack 0 win 64240".
+func (p *Packet) String() string {
+
+	return fmt.Sprintf("%d > %d [%s] seq %d ack %d win %d",
+		p.SourcePort(), p.DestinationPort(), p.tcpdumpFlags(), p.SequenceNumber(), p.AckNumber(), p.Window())
+}
+
+// DebugString renders every field on its own "key: value" line, in the
+// style of protobuf text format, with each option as a nested block. It is
+// meant for test output, where it greps and diffs well.
+func (p *Packet) DebugString() string {
+
+	reserved, ns := p.ReservedAndNS()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "src_port: %d\n", p.SourcePort())
+	fmt.Fprintf(&sb, "dst_port: %d\n", p.DestinationPort())
+	fmt.Fprintf(&sb, "seq: %d\n", p.SequenceNumber())
+	fmt.Fprintf(&sb, "ack: %d\n", p.AckNumber())
+	fmt.Fprintf(&sb, "data_offset: %d\n", p.DO())
+	fmt.Fprintf(&sb, "reserved: %d\n", reserved)
+	fmt.Fprintf(&sb, "ns: %t\n", ns)
+	fmt.Fprintf(&sb, "flags: %q\n", p.flagNames("|"))
+	fmt.Fprintf(&sb, "window: %d\n", p.Window())
+	fmt.Fprintf(&sb, "checksum: 0x%04x\n", p.Checksum())
+	fmt.Fprintf(&sb, "urgent_pointer: %d\n", p.UrgentPointer())
+
+	opts, err := p.Options()
+	for _, o := range opts {
+		sb.WriteString("option {\n")
+		fmt.Fprintf(&sb, "  kind: %d\n", o.Kind)
+		fmt.Fprintf(&sb, "  length: %d\n", o.Length)
+		fmt.Fprintf(&sb, "  data: \"%x\"\n", o.Data)
+		sb.WriteString("}\n")
+	}
+	if err != nil {
+		fmt.Fprintf(&sb, "options_error: %q\n", err.Error())
+	}
+	return sb.String()
+}
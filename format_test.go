@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFlagsColored(t *testing.T) {
+
+	defer func(v bool) { NoColor = v }(NoColor)
+	p := buildPacket(46926, 443, 1, 0, FlagSYN|FlagACK, 64240)
+
+	NoColor = false
+	got := p.FlagsColored()
+	if !strings.Contains(got, "\x1b[32mS\x1b[0m") || !strings.Contains(got, "\x1b[34m.\x1b[0m") {
+		t.Errorf("colored: FlagsColored() = %q, want SYN green and ACK blue", got)
+	}
+
+	NoColor = true
+	if got := p.FlagsColored(); got != "S." {
+		t.Errorf("NoColor: FlagsColored() = %q, want %q", got, "S.")
+	}
+}
+
+func TestBPFFilter(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1, 0, FlagSYN, 64240)
+	want := "tcp and src host 10.0.0.1 and src port 46926 and dst host 10.0.0.2 and dst port 443"
+	if got := p.BPFFilter(testSrcIP, testDstIP); got != want {
+		t.Errorf("BPFFilter = %q, want %q", got, want)
+	}
+}
+
+func TestTcpdumpLine(t *testing.T) {
+
+	p := withOptions(t, linuxSYNOptions(1460)...)
+	p.Header[16], p.Header[17] = 0x2a, 0x5a
+	want := "IP 10.0.0.1.46926 > 10.0.0.2.443: Flags [S], cksum 0x2a5a (correct), seq 2974196833, win 64240, " +
+		"options [mss 1460,sackOK,TS val 1 ecr 0,nop,wscale 7], length 0"
+	if got := p.TcpdumpLine(testSrcIP, testDstIP, 0); got != want {
+		t.Errorf("TcpdumpLine =\n%s\nwant\n%s", got, want)
+	}
+
+	p.Header[17] = 0x00
+	want = "IP 10.0.0.1.46926 > 10.0.0.2.443: Flags [S], cksum 0x2a00 (incorrect -> 0x2a5a), seq 2974196833, win 64240, " +
+		"options [mss 1460,sackOK,TS val 1 ecr 0,nop,wscale 7], length 0"
+	if got := p.TcpdumpLine(testSrcIP, testDstIP, 0); got != want {
+		t.Errorf("bad checksum: TcpdumpLine =\n%s\nwant\n%s", got, want)
+	}
+
+	data := buildPacket(46926, 443, 2974196834, 1000, FlagPSH|FlagACK, 502)
+	want = "IP 10.0.0.1.46926 > 10.0.0.2.443: Flags [P.], seq 2974196834:2974196851, ack 1000, win 502, length 17"
+	if got := data.TcpdumpLine(testSrcIP, testDstIP, 17); got != want {
+		t.Errorf("data: TcpdumpLine =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSyslogSD(t *testing.T) {
+
+	p := buildPacket(46926, 443, 2974196833, 0, FlagSYN, 64240)
+	want := `[tcp@32473 src="10.0.0.1" dst="10.0.0.2" sport="46926" dport="443" flags="SYN" seq="2974196833"]`
+	if got := p.SyslogSD(testSrcIP, testDstIP); got != want {
+		t.Errorf("SyslogSD =\n%s\nwant\n%s", got, want)
+	}
+
+	p = buildPacket(46926, 443, 1, 1, FlagPSH|FlagACK, 64240)
+	if got := p.SyslogSD(testSrcIP, testDstIP); !strings.Contains(got, ` flags="PSH,ACK" `) {
+		t.Errorf("SyslogSD = %s, want flags PSH,ACK", got)
+	}
+
+	if got, want := syslogEscaper.Replace(`a"b\c]d`), `a\"b\\c\]d`; got != want {
+		t.Errorf("escaped = %s, want %s", got, want)
+	}
+}
+
+func TestDebugString(t *testing.T) {
+
+	// The sample header claims 40 bytes but holds 20.
+	p := &Packet{Header: sampleHeader()}
+	want := `src_port: 46926
+dst_port: 443
+seq: 2974196833
+ack: 0
+data_offset: 10
+reserved: 0
+ns: false
+flags: "SYN"
+window: 64240
+checksum: 0x9bba
+urgent_pointer: 0
+options_error: "tcp: header too short"
+`
+	if got := p.DebugString(); got != want {
+		t.Errorf("DebugString() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Filling it out with an MSS option and EOL padding.
+	p.Header = append(p.Header, make([]byte, 20)...)
+	copy(p.Header[20:], []byte{2, 4, 0x05, 0xb4})
+	want = strings.Replace(want, `options_error: "tcp: header too short"
+`, `option {
+  kind: 2
+  length: 4
+  data: "05b4"
+}
+`, 1)
+	if got := p.DebugString(); got != want {
+		t.Errorf("DebugString() with MSS =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrNotTCPQuote is returned when an ICMP error does not quote a TCP segment.
+var ErrNotTCPQuote = errors.New("tcp: ICMP error does not quote a TCP segment")
+
+// FromICMPError extracts the TCP header quoted in the body of an ICMP
+// destination unreachable or time exceeded message, along with the source
+// and destination addresses of the quoted IPv4 or IPv6 header.
+//
+// Only the first 8 bytes of the TCP header (ports and sequence number) are
+// guaranteed to be quoted, so the returned Packet is usually truncated.
+// Reading it is safe: fixed fields that were not quoted read as zero,
+// while Validate returns ErrShortHeader and Options an error, so call
+// Validate before trusting anything past the sequence number.
+func FromICMPError(icmpPayload []byte) (*Packet, net.IP, net.IP, error) {
+
+	if len(icmpPayload) < 1 {
+		return nil, nil, nil, ErrShortHeader
+	}
+
+	var src, dst net.IP
+	var tcp []byte
+	switch icmpPayload[0] >> 4 {
+	case 4:
+		if len(icmpPayload) < 20 {
+			return nil, nil, nil, ErrShortHeader
+		}
+		ihl := int(icmpPayload[0]&0x0f) * 4
+		if ihl < 20 || len(icmpPayload) < ihl {
+			return nil, nil, nil, ErrShortHeader
+		}
+		if icmpPayload[9] != 6 {
+			return nil, nil, nil, ErrNotTCPQuote
+		}
+		src, dst = net.IP(icmpPayload[12:16]), net.IP(icmpPayload[16:20])
+		tcp = icmpPayload[ihl:]
+	case 6:
+		if len(icmpPayload) < 40 {
+			return nil, nil, nil, ErrShortHeader
+		}
+		if icmpPayload[6] != 6 {
+			return nil, nil, nil, ErrNotTCPQuote
+		}
+		src, dst = net.IP(icmpPayload[8:24]), net.IP(icmpPayload[24:40])
+		tcp = icmpPayload[40:]
+	default:
+		return nil, nil, nil, ErrNotTCPQuote
+	}
+
+	if len(tcp) < 8 {
+		return nil, nil, nil, ErrShortHeader
+	}
+
+	h := make([]byte, len(tcp))
+	copy(h, tcp)
+	return &Packet{Header: h}, append(net.IP(nil), src...), append(net.IP(nil), dst...), nil
+}
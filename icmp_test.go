@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// icmpQuote returns the body of an ICMP error quoting an IPv4 header from
+// src to dst followed by tcp.
+func icmpQuote(src, dst net.IP, proto byte, tcp []byte) []byte {
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	ip[9] = proto
+	copy(ip[12:16], src.To4())
+	copy(ip[16:20], dst.To4())
+	return append(ip, tcp...)
+}
+
+func TestFromICMPError(t *testing.T) {
+
+	body := icmpQuote(testSrcIP, testDstIP, 6, sampleHeader()[:8])
+	p, src, dst, err := FromICMPError(body)
+	if err != nil {
+		t.Fatalf("FromICMPError: %v", err)
+	}
+	if !src.Equal(testSrcIP) || !dst.Equal(testDstIP) {
+		t.Errorf("addresses = %v, %v; want %v, %v", src, dst, testSrcIP, testDstIP)
+	}
+	if p.SourcePort() != 46926 || p.DestinationPort() != 443 || p.SequenceNumber() != 2974196833 {
+		t.Errorf("quoted fields = %d, %d, %d; want 46926, 443, 2974196833", p.SourcePort(), p.DestinationPort(), p.SequenceNumber())
+	}
+
+	// Nothing past the sequence number was quoted: reads must not panic.
+	if err := p.Validate(); err != ErrShortHeader {
+		t.Errorf("Validate() = %v, want ErrShortHeader", err)
+	}
+	if _, err := p.Options(); err == nil {
+		t.Error("Options() succeeded on an 8-byte quote")
+	}
+	if p.AckNumber() != 0 || p.Window() != 0 || p.Checksum() != 0 || p.UrgentPointer() != 0 || p.DO() != 0 {
+		t.Error("unquoted fields do not read as zero")
+	}
+	if p.FixedHeader() != nil {
+		t.Error("FixedHeader() of an 8-byte quote is not nil")
+	}
+	if (Segment{Packet: p}).VerifyChecksum(src, dst) {
+		t.Error("VerifyChecksum() succeeded on an 8-byte quote")
+	}
+	if want := "46926 > 443 [none] seq 2974196833 ack 0 win 0"; p.String() != want {
+		t.Errorf("String() = %q, want %q", p.String(), want)
+	}
+	_ = p.DebugString()
+	_ = p.Flags()
+	_ = p.RSV()
+	_ = p.ToRecord()
+	_ = p.MarshalCompact()
+	_ = p.NetFlowFields(src, dst)
+	_ = p.TcpdumpLine(src, dst, 0)
+
+	v6 := make([]byte, 40)
+	v6[0], v6[6] = 0x60, 6
+	src6, dst6 := net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")
+	copy(v6[8:24], src6)
+	copy(v6[24:40], dst6)
+	p, src, dst, err = FromICMPError(append(v6, buildPacket(46926, 443, 1, 0, FlagSYN, 64240).Header...))
+	if err != nil || !src.Equal(src6) || !dst.Equal(dst6) || p.Validate() != nil {
+		t.Errorf("IPv6 full quote: %v, %v, %v", src, dst, err)
+	}
+
+	full := buildPacket(46926, 443, 1, 1, FlagSYN|FlagACK, 64240)
+	full.Header[12] |= 0x0a // reserved bits 101
+	p, _, _, err = FromICMPError(icmpQuote(testSrcIP, testDstIP, 6, full.Header))
+	if err != nil {
+		t.Fatalf("full quote: %v", err)
+	}
+	if f := p.Flags(); !f.SYN || !f.ACK || f.RST || f.FIN || f.PSH || f.URG {
+		t.Errorf("full quote: Flags() = %+v, want SYN and ACK", f)
+	}
+	if got := p.RSV(); got != 5 {
+		t.Errorf("full quote: RSV() = %d, want 5", got)
+	}
+
+	if _, _, _, err := FromICMPError(icmpQuote(testSrcIP, testDstIP, 17, sampleHeader()[:8])); err != ErrNotTCPQuote {
+		t.Errorf("UDP quote: err = %v, want ErrNotTCPQuote", err)
+	}
+	if _, _, _, err := FromICMPError(icmpQuote(testSrcIP, testDstIP, 6, sampleHeader()[:7])); err != ErrShortHeader {
+		t.Errorf("7-byte quote: err = %v, want ErrShortHeader", err)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
-	"strconv"
 )
 
 type Packet struct {
@@ -38,6 +37,9 @@ func (p *Packet) SequenceNumber() uint32 {
 // messages that are either recently received or expected to be sent.
 func (p *Packet) AckNumber() uint32 {
 
+	if len(p.Header) < 12 {
+		return 0
+	}
 	return binary.BigEndian.Uint32(p.Header[8:12])
 }
 
@@ -47,19 +49,52 @@ func (p *Packet) AckNumber() uint32 {
 // using the maximum-sized optional field has a data offset of 15 (representing 60 bytes).
 func (p *Packet) DO() uint8 {
 
-	do := fmt.Sprintf("%b", p.Header[12:14][0])
-	output, _ := strconv.ParseInt(do[0:4], 2, 5)
-	return uint8(output)
+	if len(p.Header) < 13 {
+		return 0
+	}
+	return p.Header[12] >> 4
+}
+
+// SetDataOffset writes the data offset, in 32-bit words, leaving the
+// reserved bits and flags untouched. words must be between 5 and 15 and
+// the header must be long enough to hold that many words.
+func (p *Packet) SetDataOffset(words uint8) error {
+
+	if words < 5 || words > 15 {
+		return ErrBadDataOffset
+	}
+	if len(p.Header) < int(words)*4 {
+		return ErrShortHeader
+	}
+	p.Header[12] = words<<4 | p.Header[12]&0x0f
+	return nil
+}
+
+// IsStandardHeader reports whether the header has no options, i.e. a data
+// offset of exactly 5. Most data segments are like this.
+func (p *Packet) IsStandardHeader() bool {
+
+	return p.DO() == 5
 }
 
 // RSV Reserved data (3 bits): Reserved data in TCP headers always has a value of zero.
 // This field aligns the total header size as a multiple of four bytes,
 // which is important for the efficiency of computer data processing.
 func (p *Packet) RSV() uint8 {
-	rs := fmt.Sprintf("%b", p.Header[12:14][0])
-	output, _ := strconv.ParseInt(rs[4:7], 2, 4)
 
-	return uint8(output)
+	reserved, _ := p.ReservedAndNS()
+	return reserved
+}
+
+// ReservedAndNS Reserved bits and NS (4 bits): the low nibble of byte 12 holds
+// the 3-bit reserved field followed by the NS (ECN nonce) bit of RFC 3540.
+// RFC 9293 returned NS to the reserved pool, so both views are given here.
+func (p *Packet) ReservedAndNS() (reserved uint8, ns bool) {
+
+	if len(p.Header) < 13 {
+		return 0, false
+	}
+	return p.Header[12] >> 1 & 0x07, p.Header[12]&0x01 != 0
 }
 
 // Flags Control flags (up to 9 bits): TCP uses a set of six standard and
@@ -73,10 +108,8 @@ func (p *Packet) Flags() struct {
 	PSH bool
 	URG bool
 } {
-	fg1 := fmt.Sprintf("%.1b", p.Header[12:14][0])
-	fg2 := fmt.Sprintf("%.8b", p.Header[12:14][1])
 
-	data := struct {
+	return struct {
 		SYN bool
 		ACK bool
 		RST bool
@@ -84,39 +117,13 @@ func (p *Packet) Flags() struct {
 		PSH bool
 		URG bool
 	}{
-		SYN: false, 
-		ACK: false, 
-		RST: false, 
-		FIN: false, 
-		PSH: false, 
-		URG: false,
-	}
-
-	if fg1[7:8] != "0" {
-		data.SYN = true
-	}
-
-	if fg2[0:1] != "0" {
-		data.ACK = true
-	}
-
-	if fg2[1:2] != "0" {
-		data.RST = true
+		SYN: p.hasFlag(FlagSYN),
+		ACK: p.hasFlag(FlagACK),
+		RST: p.hasFlag(FlagRST),
+		FIN: p.hasFlag(FlagFIN),
+		PSH: p.hasFlag(FlagPSH),
+		URG: p.hasFlag(FlagURG),
 	}
-
-	if fg2[2:3] != "0" {
-		data.FIN = true
-	}
-
-	if fg2[3:4] != "0" {
-		data.PSH = true
-	}
-
-	if fg2[4:5] != "0"{
-		data.URG = true
-	}
-
-	return data
 }
 
 // Window Window size (2 bytes or 16 bits): TCP senders use a number,
@@ -130,6 +137,9 @@ func (p *Packet) Flags() struct {
 // coordinate changes between senders and receivers.
 func (p *Packet) Window() uint16 {
 
+	if len(p.Header) < 16 {
+		return 0
+	}
 	return binary.BigEndian.Uint16(p.Header[14:16])
 }
 
@@ -138,6 +148,9 @@ func (p *Packet) Window() uint16 {
 // to help the receiver detect messages that are corrupted or tampered with.
 func (p *Packet) Checksum() uint16 {
 
+	if len(p.Header) < 18 {
+		return 0
+	}
 	return binary.BigEndian.Uint16(p.Header[16:18])
 }
 
@@ -147,14 +160,46 @@ func (p *Packet) Checksum() uint16 {
 // requiring priority processing.
 func (p *Packet) UrgentPointer() uint16 {
 
+	if len(p.Header) < 20 {
+		return 0
+	}
 	return binary.BigEndian.Uint16(p.Header[18:20])
 }
 
-// // Options TCP optional data (0 to 40 bytes): Usages of optional TCP data
-// // include support for special acknowledgment and window scaling algorithms.
-// func (p *Packet) Options() {
-// 	fmt.Println("Options")
-// }
+// FixedHeader returns a copy of the fixed 20-byte portion of the header,
+// without any options, so two packets can be compared field for field.
+// It returns nil if the header is shorter than 20 bytes.
+func (p *Packet) FixedHeader() []byte {
+
+	if len(p.Header) < 20 {
+		return nil
+	}
+	fixed := make([]byte, 20)
+	copy(fixed, p.Header[:20])
+	return fixed
+}
+
+// FixedArray copies the fixed 20-byte header into an array, which can stay
+// on the stack. ok is false, and the array zero, if the header is shorter.
+func (p *Packet) FixedArray() ([20]byte, bool) {
+
+	var fixed [20]byte
+	if len(p.Header) < 20 {
+		return fixed, false
+	}
+	copy(fixed[:], p.Header)
+	return fixed, true
+}
+
+// QuotedPrefix returns the first 8 bytes of the header: the ports and the
+// sequence number, which is all an ICMP error is guaranteed to quote. A
+// shorter header is zero-padded.
+func (p *Packet) QuotedPrefix() [8]byte {
+
+	var prefix [8]byte
+	copy(prefix[:], p.Header)
+	return prefix
+}
 
 func main() {
 
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// sampleHeader is the SYN printed by main.
+func sampleHeader() []byte {
+
+	return []byte{
+		0xb7, 0x4e,
+		0x01, 0xbb,
+		0xb1, 0x46,
+		0xa4, 0x61,
+		0x00, 0x00,
+		0x00, 0x00,
+		0xa0, 0x02,
+		0xfa, 0xf0,
+		0x9b, 0xba,
+		0x00, 0x00,
+	}
+}
+
+func TestFixedHeader(t *testing.T) {
+
+	p := &Packet{Header: append(sampleHeader(), make([]byte, 20)...)}
+	fixed := p.FixedHeader()
+	if !bytes.Equal(fixed, sampleHeader()) {
+		t.Fatalf("FixedHeader() = %x, want %x", fixed, sampleHeader())
+	}
+
+	fixed[0] = 0xff
+	if p.Header[0] != 0xb7 {
+		t.Errorf("writing the copy changed the packet: Header[0] = %#x", p.Header[0])
+	}
+	p.Header[1] = 0xff
+	if fixed[1] != 0x4e {
+		t.Errorf("writing the packet changed the copy: fixed[1] = %#x", fixed[1])
+	}
+
+	short := &Packet{Header: sampleHeader()[:12]}
+	if got := short.FixedHeader(); got != nil {
+		t.Errorf("FixedHeader() of a 12-byte header = %x, want nil", got)
+	}
+}
+
+func TestSetDataOffset(t *testing.T) {
+
+	p := &Packet{Header: append(sampleHeader(), make([]byte, 12)...)}
+	if got := p.DO(); got != 10 {
+		t.Fatalf("DO() of the sample header = %d, want 10", got)
+	}
+	p.Header[12] |= 0x0f // reserved bits and NS
+	p.Header[13] = 0x12  // SYN-ACK
+	if err := p.SetDataOffset(8); err != nil {
+		t.Fatalf("SetDataOffset(8): %v", err)
+	}
+	if got := p.DO(); got != 8 {
+		t.Errorf("DO() = %d, want 8", got)
+	}
+	if p.Header[12]&0x0f != 0x0f || p.Header[13] != 0x12 {
+		t.Errorf("bytes 12-13 = %#02x %#02x, want low nibble 0xf and 0x12", p.Header[12], p.Header[13])
+	}
+
+	if err := p.SetDataOffset(4); err != ErrBadDataOffset {
+		t.Errorf("SetDataOffset(4): err = %v, want ErrBadDataOffset", err)
+	}
+	if err := p.SetDataOffset(9); err != ErrShortHeader {
+		t.Errorf("SetDataOffset(9) on 32 bytes: err = %v, want ErrShortHeader", err)
+	}
+	if got := p.DO(); got != 8 {
+		t.Errorf("DO() after rejected writes = %d, want 8", got)
+	}
+}
+
+func TestReservedAndNS(t *testing.T) {
+
+	tests := []struct {
+		b12      byte
+		reserved uint8
+		ns       bool
+	}{
+		{0x50, 0, false},
+		{0x51, 0, true},
+		{0x5e, 7, false},
+		{0x5b, 5, true},
+	}
+	for _, tt := range tests {
+		p := &Packet{Header: sampleHeader()}
+		p.Header[12] = tt.b12
+		reserved, ns := p.ReservedAndNS()
+		if reserved != tt.reserved || ns != tt.ns {
+			t.Errorf("byte 12 = %#02x: ReservedAndNS() = %d, %v; want %d, %v", tt.b12, reserved, ns, tt.reserved, tt.ns)
+		}
+	}
+}
+
+func TestQuotedPrefix(t *testing.T) {
+
+	p := &Packet{Header: sampleHeader()}
+	want := [8]byte{0xb7, 0x4e, 0x01, 0xbb, 0xb1, 0x46, 0xa4, 0x61}
+	if got := p.QuotedPrefix(); got != want {
+		t.Errorf("QuotedPrefix() = %x, want %x", got, want)
+	}
+
+	short := &Packet{Header: sampleHeader()[:5]}
+	want = [8]byte{0xb7, 0x4e, 0x01, 0xbb, 0xb1}
+	if got := short.QuotedPrefix(); got != want {
+		t.Errorf("5 bytes: QuotedPrefix() = %x, want %x", got, want)
+	}
+}
+
+func TestFixedArray(t *testing.T) {
+
+	p := &Packet{Header: append(sampleHeader(), 1, 1, 1, 1)}
+	fixed, ok := p.FixedArray()
+	if !ok || !bytes.Equal(fixed[:], sampleHeader()) {
+		t.Errorf("FixedArray() = %x, %v; want %x, true", fixed, ok, sampleHeader())
+	}
+
+	short := &Packet{Header: sampleHeader()[:19]}
+	if fixed, ok := short.FixedArray(); ok || fixed != [20]byte{} {
+		t.Errorf("19 bytes: FixedArray() = %x, %v; want zero, false", fixed, ok)
+	}
+}
+
+func TestFlags(t *testing.T) {
+
+	tests := []struct {
+		flags uint16
+		want  string
+	}{
+		{FlagSYN, "{SYN:true ACK:false RST:false FIN:false PSH:false URG:false}"},
+		{FlagFIN | FlagACK, "{SYN:false ACK:true RST:false FIN:true PSH:false URG:false}"},
+		{FlagPSH | FlagACK | FlagURG, "{SYN:false ACK:true RST:false FIN:false PSH:true URG:true}"},
+		{FlagRST | FlagCWR | FlagNS, "{SYN:false ACK:false RST:true FIN:false PSH:false URG:false}"},
+	}
+	for _, tt := range tests {
+		p := buildPacket(46926, 443, 1, 1, tt.flags, 64240)
+		if got := fmt.Sprintf("%+v", p.Flags()); got != tt.want {
+			t.Errorf("flags %#03x: Flags() = %s, want %s", tt.flags, got, tt.want)
+		}
+	}
+
+	// The sample header has a data offset of 10, so byte 12 is 0xa0.
+	if f := (&Packet{Header: sampleHeader()}).Flags(); !f.SYN || f.ACK {
+		t.Errorf("sample header: Flags() = %+v, want SYN only", f)
+	}
+	if f := (&Packet{Header: sampleHeader()[:13]}).Flags(); f.SYN {
+		t.Errorf("13-byte header: Flags() = %+v, want none", f)
+	}
+}
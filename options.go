@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Option kinds as assigned by IANA.
+const (
+	OptionEOL       uint8 = 0
+	OptionNOP       uint8 = 1
+	OptionMSS       uint8 = 2
+	OptionWScale    uint8 = 3
+	OptionSACKPerm  uint8 = 4
+	OptionSACK      uint8 = 5
+	OptionTimestamp uint8 = 8
+	OptionFastOpen  uint8 = 34
+	OptionENO       uint8 = 69
+	OptionExp1      uint8 = 253
+	OptionExp2      uint8 = 254
+)
+
+// Experiment IDs (RFC 6994) carried at the start of kind 253/254 options.
+var (
+	// fastOpenExID is the ID used by TFO before kind 34 was assigned.
+	fastOpenExID = []byte{0xf9, 0x89}
+	// smcrExID is "SMCR" in EBCDIC, the ID of the SMC-R rendezvous option (RFC 7609).
+	smcrExID = []byte{0xe2, 0xd4, 0xc3, 0xd9}
+)
+
+var (
+	// ErrShortHeader is returned when the header is shorter than its data offset claims.
+	ErrShortHeader = errors.New("tcp: header too short")
+	// ErrBadDataOffset is returned when the data offset is outside 5-15 words.
+	ErrBadDataOffset = errors.New("tcp: bad data offset")
+	// ErrTruncatedOption is returned when an option runs past the end of the options region.
+	ErrTruncatedOption = errors.New("tcp: truncated option")
+	// ErrBadOptionLength is returned when a known option carries the wrong length.
+	ErrBadOptionLength = errors.New("tcp: bad option length")
+	// ErrOptionsTooLong is returned when options do not fit in 40 bytes.
+	ErrOptionsTooLong = errors.New("tcp: options exceed 40 bytes")
+)
+
+// optionLengths holds the fixed wire length of the options that have one.
+var optionLengths = map[uint8]uint8{
+	OptionMSS:       4,
+	OptionWScale:    3,
+	OptionSACKPerm:  2,
+	OptionTimestamp: 10,
+}
+
+// ParseOptions controls how the options region is decoded.
+type ParseOptions struct {
+	// StrictLengths makes a known option with the wrong length an error.
+	// Options of unknown kind have no expected length and never fail.
+	// Otherwise it is reported as a warning and skipped by its declared length.
+	StrictLengths bool
+}
+
+// Option a single TCP option as it appears on the wire. Length is the
+// value of the length byte (kind and length included), Data is what follows it.
+// Kinds without a dedicated accessor are read straight from these fields.
+type Option struct {
+	Kind   uint8
+	Length uint8
+	Data   []byte
+}
+
+// headerLen returns the header length in bytes as given by the data offset,
+// or 0 when the header is too short to hold the data offset.
+func (p *Packet) headerLen() int {
+
+	if len(p.Header) < 13 {
+		return 0
+	}
+	return int(p.Header[12]>>4) * 4
+}
+
+// Options TCP optional data (0 to 40 bytes): Usages of optional TCP data
+// include support for special acknowledgment and window scaling algorithms.
+// NOP and EOL are consumed as padding and are not returned. Options are
+// decoded leniently; use OptionsWith for strict length checking.
+//
+// Every option with a well-formed kind and length byte is returned, whether
+// or not this package knows its kind, so new and draft options come back as
+// a plain Option instead of an error. Only a truncated option is an error.
+func (p *Packet) Options() ([]Option, error) {
+
+	opts, _, err := p.OptionsWith(ParseOptions{})
+	return opts, err
+}
+
+// OptionsWith decodes the options region according to cfg. In lenient mode
+// every known option with the wrong length is returned in warnings, and the
+// option itself is still included in opts.
+func (p *Packet) OptionsWith(cfg ParseOptions) (opts []Option, warnings []error, err error) {
+
+	return p.walkOptions(cfg, false)
+}
+
+// walkOptions does the work of OptionsWith. With padding set, NOP and EOL
+// are returned too, with a Length of 1, so the exact layout can be compared.
+func (p *Packet) walkOptions(cfg ParseOptions, padding bool) (opts []Option, warnings []error, err error) {
+
+	// Fast path for the common option-less header.
+	if len(p.Header) >= 20 && p.IsStandardHeader() {
+		return nil, nil, nil
+	}
+
+	end := p.headerLen()
+	if end < 20 || len(p.Header) < end {
+		return nil, nil, ErrShortHeader
+	}
+
+	b := p.Header[20:end]
+	for i := 0; i < len(b); {
+		kind := b[i]
+		if kind == OptionEOL || kind == OptionNOP {
+			if padding {
+				opts = append(opts, Option{Kind: kind, Length: 1})
+			}
+			if kind == OptionEOL {
+				break
+			}
+			i++
+			continue
+		}
+		if i+1 >= len(b) {
+			return opts, warnings, ErrTruncatedOption
+		}
+		length := int(b[i+1])
+		if length < 2 || i+length > len(b) {
+			return opts, warnings, ErrTruncatedOption
+		}
+		if want, ok := optionLengths[kind]; ok && int(want) != length {
+			bad := fmt.Errorf("%w: kind %d has length %d, want %d", ErrBadOptionLength, kind, length, want)
+			if cfg.StrictLengths {
+				return opts, warnings, bad
+			}
+			warnings = append(warnings, bad)
+		}
+		opts = append(opts, Option{Kind: kind, Length: uint8(length), Data: b[i+2 : i+length]})
+		i += length
+	}
+
+	return opts, warnings, nil
+}
+
+// findOption returns the first option of the given kind.
+func (p *Packet) findOption(kind uint8) (Option, bool) {
+
+	opts, _ := p.Options()
+	for _, o := range opts {
+		if o.Kind == kind {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// FastOpenCookie TCP Fast Open cookie (RFC 7413): returns the cookie carried
+// in kind 34, or in the experimental form with the 0xf989 experiment ID.
+// An empty cookie means the sender is requesting one.
+func (p *Packet) FastOpenCookie() ([]byte, bool) {
+
+	if o, ok := p.findOption(OptionFastOpen); ok {
+		return o.Data, true
+	}
+
+	return p.findExperiment(fastOpenExID)
+}
+
+// findExperiment returns what follows exid in the first experimental
+// option (kind 253 or 254) that starts with it.
+func (p *Packet) findExperiment(exid []byte) ([]byte, bool) {
+
+	opts, _ := p.Options()
+	for _, o := range opts {
+		if (o.Kind == OptionExp1 || o.Kind == OptionExp2) && bytes.HasPrefix(o.Data, exid) {
+			return o.Data[len(exid):], true
+		}
+	}
+	return nil, false
+}
+
+// FastOpenCookieValid is like FastOpenCookie but reports valid=false when the
+// cookie is not between 4 and 16 bytes, as required by RFC 7413. An empty
+// cookie is a valid cookie request and is reported valid.
+func (p *Packet) FastOpenCookieValid() (cookie []byte, valid bool) {
+
+	cookie, ok := p.FastOpenCookie()
+	if !ok {
+		return nil, false
+	}
+	return cookie, len(cookie) == 0 || len(cookie) >= 4 && len(cookie) <= 16
+}
+
+// DefaultMSS is the MSS a sender must assume when the peer does not advertise one (RFC 9293).
+const DefaultMSS uint16 = 536
+
+// MSS Maximum segment size option (kind 2): the largest segment the sender
+// is willing to receive. Only meaningful on SYN segments.
+func (p *Packet) MSS() (uint16, bool) {
+
+	o, ok := p.findOption(OptionMSS)
+	if !ok || len(o.Data) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(o.Data), true
+}
+
+// NegotiatedMSS returns the MSS in effect for a connection given the values
+// advertised in the SYN and the SYN-ACK. A zero value means the side did not
+// advertise one and DefaultMSS is used in its place.
+func NegotiatedMSS(synMSS, synAckMSS uint16) uint16 {
+
+	if synMSS == 0 {
+		synMSS = DefaultMSS
+	}
+	if synAckMSS == 0 {
+		synAckMSS = DefaultMSS
+	}
+	if synAckMSS < synMSS {
+		return synAckMSS
+	}
+	return synMSS
+}
+
+// OptionsMatch compares the option layout of two packets, NOP and EOL
+// padding included. With ignoreValues only the kinds and their order are
+// compared, which is how a SYN is matched against a fingerprint template.
+func OptionsMatch(a, b *Packet, ignoreValues bool) bool {
+
+	oa, _, err := a.walkOptions(ParseOptions{}, true)
+	if err != nil {
+		return false
+	}
+	ob, _, err := b.walkOptions(ParseOptions{}, true)
+	if err != nil || len(oa) != len(ob) {
+		return false
+	}
+	for i := range oa {
+		if oa[i].Kind != ob[i].Kind {
+			return false
+		}
+		if !ignoreValues && (oa[i].Length != ob[i].Length || !bytes.Equal(oa[i].Data, ob[i].Data)) {
+			return false
+		}
+	}
+	return true
+}
+
+// OptionsStripped returns the option kinds in the client's SYN that are
+// missing from the server's SYN-ACK, in SYN order. Options the server
+// should echo, such as SACK-permitted and timestamps, showing up here point
+// to a middlebox removing them.
+func OptionsStripped(clientSyn, serverSynAck *Packet) []uint8 {
+
+	synOpts, _ := clientSyn.Options()
+	ackOpts, _ := serverSynAck.Options()
+
+	seen := make(map[uint8]bool)
+	for _, o := range ackOpts {
+		seen[o.Kind] = true
+	}
+
+	var stripped []uint8
+	for _, o := range synOpts {
+		if !seen[o.Kind] {
+			stripped = append(stripped, o.Kind)
+			seen[o.Kind] = true
+		}
+	}
+	return stripped
+}
+
+// ENOSuites TCP-ENO option (kind 69, RFC 8547): the suboption bytes of the
+// encryption negotiation option, each naming an encryption spec (suite) the
+// sender supports. An option with no suboptions is reported as absent.
+func (p *Packet) ENOSuites() ([]byte, bool) {
+
+	o, ok := p.findOption(OptionENO)
+	if !ok || len(o.Data) < 1 {
+		return nil, false
+	}
+	return o.Data, true
+}
+
+// maxAlignNOPs is the longest run of NOPs 4-byte alignment can call for.
+const maxAlignNOPs = 3
+
+// HasExcessiveNOPs reports whether the options region holds a run of more
+// NOPs than alignment needs, a known IDS evasion trick. Runs are counted
+// separately because stacks pad before each option, e.g. two NOPs before
+// both timestamps and SACK blocks.
+func (p *Packet) HasExcessiveNOPs() bool {
+
+	opts, _, _ := p.walkOptions(ParseOptions{}, true)
+	run := 0
+	for _, o := range opts {
+		if o.Kind != OptionNOP {
+			run = 0
+			continue
+		}
+		run++
+		if run > maxAlignNOPs {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxOptionsLen is the room the 4-bit data offset leaves for options.
+const MaxOptionsLen = 40
+
+// MarshalOptions encodes opts in wire format, padded with EOL to a multiple
+// of 4 bytes. NOP and EOL are written as single bytes; every other option is
+// written as kind, length and data, and a nonzero Length that disagrees with
+// the data is an error.
+func MarshalOptions(opts []Option) ([]byte, error) {
+
+	var b []byte
+	for _, o := range opts {
+		if o.Kind == OptionEOL || o.Kind == OptionNOP {
+			b = append(b, o.Kind)
+			continue
+		}
+		length := 2 + len(o.Data)
+		if length > 255 || o.Length != 0 && int(o.Length) != length {
+			return nil, fmt.Errorf("%w: kind %d has length %d and %d data bytes", ErrBadOptionLength, o.Kind, o.Length, len(o.Data))
+		}
+		b = append(b, o.Kind, uint8(length))
+		b = append(b, o.Data...)
+	}
+	for len(b)%4 != 0 {
+		b = append(b, OptionEOL)
+	}
+	if len(b) > MaxOptionsLen {
+		return nil, ErrOptionsTooLong
+	}
+	return b, nil
+}
+
+// Timestamps Timestamps option (kind 8, RFC 7323): the sender's clock value
+// TSval and TSecr, the most recent TSval echoed back from the peer.
+func (p *Packet) Timestamps() (tsval, tsecr uint32, ok bool) {
+
+	o, ok := p.findOption(OptionTimestamp)
+	if !ok || len(o.Data) != 8 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(o.Data[0:4]), binary.BigEndian.Uint32(o.Data[4:8]), true
+}
+
+// SACKBlocks SACK option (kind 5, RFC 2018): the blocks of out-of-order data
+// the receiver holds, each as [left edge, right edge). A trailing partial
+// block is ignored.
+func (p *Packet) SACKBlocks() ([][2]uint32, bool) {
+
+	o, ok := p.findOption(OptionSACK)
+	if !ok {
+		return nil, false
+	}
+	return sackBlocks(o.Data), true
+}
+
+func sackBlocks(b []byte) [][2]uint32 {
+
+	blocks := make([][2]uint32, 0, len(b)/8)
+	for i := 0; i+8 <= len(b); i += 8 {
+		blocks = append(blocks, [2]uint32{binary.BigEndian.Uint32(b[i:]), binary.BigEndian.Uint32(b[i+4:])})
+	}
+	return blocks
+}
+
+// RTTSampleKey returns the segment's TSval, the key under which to file it
+// until a later segment from the peer echoes the value in TSecr and so
+// closes an RTT sample. It returns false when there is no timestamp.
+func (p *Packet) RTTSampleKey() (uint32, bool) {
+
+	tsval, _, ok := p.Timestamps()
+	return tsval, ok
+}
+
+// IsSMCRendezvous reports whether the packet carries the SMC-R rendezvous
+// option (RFC 7609): an experimental option with the SMC-R experiment ID,
+// sent on the SYN and SYN-ACK of connections that may switch to RDMA.
+func (p *Packet) IsSMCRendezvous() bool {
+
+	_, ok := p.findExperiment(smcrExID)
+	return ok
+}
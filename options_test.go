@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// withOptions returns a SYN carrying opts, with the data offset set to cover them.
+func withOptions(t *testing.T, opts ...Option) *Packet {
+
+	t.Helper()
+	b, err := MarshalOptions(opts)
+	if err != nil {
+		t.Fatalf("MarshalOptions: %v", err)
+	}
+	p := buildPacket(46926, 443, 2974196833, 0, FlagSYN, 64240)
+	p.Header = append(p.Header, b...)
+	if err := p.SetDataOffset(uint8(len(p.Header) / 4)); err != nil {
+		t.Fatalf("SetDataOffset: %v", err)
+	}
+	return p
+}
+
+func TestFastOpenCookieValid(t *testing.T) {
+
+	cookie := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	p := withOptions(t, Option{Kind: OptionFastOpen, Data: cookie})
+	got, valid := p.FastOpenCookieValid()
+	if !valid || !bytes.Equal(got, cookie) {
+		t.Errorf("8-byte cookie: got %x, %v; want %x, true", got, valid, cookie)
+	}
+
+	long := bytes.Repeat([]byte{0xaa}, 20)
+	p = withOptions(t, Option{Kind: OptionFastOpen, Data: long})
+	got, valid = p.FastOpenCookieValid()
+	if valid || !bytes.Equal(got, long) {
+		t.Errorf("20-byte cookie: got %x, %v; want %x, false", got, valid, long)
+	}
+
+	p = withOptions(t, Option{Kind: OptionFastOpen, Data: []byte{1, 2}})
+	if _, valid = p.FastOpenCookieValid(); valid {
+		t.Error("2-byte cookie reported valid")
+	}
+
+	p = withOptions(t, Option{Kind: OptionFastOpen})
+	got, valid = p.FastOpenCookieValid()
+	if !valid || len(got) != 0 {
+		t.Errorf("cookie request: got %x, %v; want an empty cookie, true", got, valid)
+	}
+	if _, valid = withOptions(t, linuxSYNOptions(1460)...).FastOpenCookieValid(); valid {
+		t.Error("SYN without Fast Open reported valid")
+	}
+}
+
+func TestNegotiatedMSS(t *testing.T) {
+
+	tests := []struct {
+		syn, synAck, want uint16
+	}{
+		{1460, 1380, 1380},
+		{1380, 1460, 1380},
+		{0, 1460, DefaultMSS},
+		{1460, 0, DefaultMSS},
+		{0, 0, DefaultMSS},
+	}
+	for _, tt := range tests {
+		if got := NegotiatedMSS(tt.syn, tt.synAck); got != tt.want {
+			t.Errorf("NegotiatedMSS(%d, %d) = %d, want %d", tt.syn, tt.synAck, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsWithStrictLengths(t *testing.T) {
+
+	// A timestamp option with 6 data bytes instead of 8.
+	p := withOptions(t, Option{Kind: OptionTimestamp, Data: []byte{0, 0, 0, 1, 0, 0}})
+
+	_, _, err := p.OptionsWith(ParseOptions{StrictLengths: true})
+	if !errors.Is(err, ErrBadOptionLength) {
+		t.Errorf("strict: err = %v, want ErrBadOptionLength", err)
+	}
+
+	opts, warnings, err := p.OptionsWith(ParseOptions{})
+	if err != nil {
+		t.Fatalf("lenient: err = %v", err)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrBadOptionLength) {
+		t.Errorf("lenient: warnings = %v, want one ErrBadOptionLength", warnings)
+	}
+	if len(opts) != 1 || opts[0].Kind != OptionTimestamp || opts[0].Length != 8 {
+		t.Errorf("lenient: opts = %+v, want the 8-byte timestamp option", opts)
+	}
+}
+
+// linuxSYNOptions returns the option layout of a Linux SYN with the given MSS.
+func linuxSYNOptions(mss uint16) []Option {
+
+	return []Option{
+		{Kind: OptionMSS, Data: []byte{byte(mss >> 8), byte(mss)}},
+		{Kind: OptionSACKPerm},
+		{Kind: OptionTimestamp, Data: []byte{0, 0, 0, 1, 0, 0, 0, 0}},
+		{Kind: OptionNOP},
+		{Kind: OptionWScale, Data: []byte{7}},
+	}
+}
+
+func TestOptionsMatch(t *testing.T) {
+
+	a := withOptions(t, linuxSYNOptions(1460)...)
+	b := withOptions(t, linuxSYNOptions(1400)...)
+	if !OptionsMatch(a, b, true) {
+		t.Error("ignoring values: SYNs differing only in MSS do not match")
+	}
+	if OptionsMatch(a, b, false) {
+		t.Error("comparing values: SYNs with different MSS match")
+	}
+	if !OptionsMatch(a, withOptions(t, linuxSYNOptions(1460)...), false) {
+		t.Error("identical SYNs do not match")
+	}
+
+	reordered := withOptions(t, linuxSYNOptions(1460)[1:]...)
+	if OptionsMatch(a, reordered, true) {
+		t.Error("SYN without MSS matches one with it")
+	}
+}
+
+func TestOptionsStripped(t *testing.T) {
+
+	syn := withOptions(t, linuxSYNOptions(1460)...)
+	synAck := withOptions(t,
+		Option{Kind: OptionMSS, Data: []byte{0x05, 0xb4}},
+		Option{Kind: OptionSACKPerm},
+		Option{Kind: OptionNOP},
+		Option{Kind: OptionWScale, Data: []byte{7}},
+	)
+	got := OptionsStripped(syn, synAck)
+	if len(got) != 1 || got[0] != OptionTimestamp {
+		t.Errorf("OptionsStripped = %v, want [%d]", got, OptionTimestamp)
+	}
+
+	if got := OptionsStripped(syn, syn); len(got) != 0 {
+		t.Errorf("echoed SYN: OptionsStripped = %v, want none", got)
+	}
+}
+
+func TestENOSuites(t *testing.T) {
+
+	p := withOptions(t, Option{Kind: OptionENO, Data: []byte{0x20, 0x21}})
+	suites, ok := p.ENOSuites()
+	if !ok || !bytes.Equal(suites, []byte{0x20, 0x21}) {
+		t.Errorf("ENOSuites() = %x, %v; want 2021, true", suites, ok)
+	}
+
+	if _, ok := withOptions(t, Option{Kind: OptionENO}).ENOSuites(); ok {
+		t.Error("empty ENO option reported suites")
+	}
+	if _, ok := withOptions(t, linuxSYNOptions(1460)...).ENOSuites(); ok {
+		t.Error("SYN without ENO reported suites")
+	}
+}
+
+func TestHasExcessiveNOPs(t *testing.T) {
+
+	if withOptions(t, linuxSYNOptions(1460)...).HasExcessiveNOPs() {
+		t.Error("Linux SYN layout flagged")
+	}
+	aligned := withOptions(t,
+		Option{Kind: OptionNOP}, Option{Kind: OptionNOP},
+		Option{Kind: OptionTimestamp, Data: make([]byte, 8)},
+		Option{Kind: OptionNOP}, Option{Kind: OptionNOP},
+		Option{Kind: OptionSACK, Data: make([]byte, 8)},
+	)
+	if aligned.HasExcessiveNOPs() {
+		t.Error("two separate NOP pairs flagged")
+	}
+
+	nops := make([]Option, 12)
+	for i := range nops {
+		nops[i].Kind = OptionNOP
+	}
+	if !withOptions(t, append(nops, Option{Kind: OptionMSS, Data: []byte{0x05, 0xb4}})...).HasExcessiveNOPs() {
+		t.Error("12 NOPs in a row not flagged")
+	}
+}
+
+func TestOptionsUnknownKind(t *testing.T) {
+
+	p := withOptions(t,
+		Option{Kind: OptionMSS, Data: []byte{0x05, 0xb4}},
+		Option{Kind: 200, Data: []byte{0xde, 0xad, 0xbe}},
+	)
+	opts, err := p.Options()
+	if err != nil {
+		t.Fatalf("Options() = %v", err)
+	}
+	if len(opts) != 2 {
+		t.Fatalf("got %d options, want 2", len(opts))
+	}
+	o := opts[1]
+	if o.Kind != 200 || o.Length != 5 || !bytes.Equal(o.Data, []byte{0xde, 0xad, 0xbe}) {
+		t.Errorf("kind 200 option = %+v, want length 5 with data deadbe", o)
+	}
+	if err := p.ParseCheck(); err != nil {
+		t.Errorf("strict ParseCheck() = %v; unknown kinds have no expected length", err)
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+
+	b, err := MarshalOptions([]Option{
+		{Kind: OptionMSS, Data: []byte{0x05, 0xb4}},
+		{Kind: OptionSACKPerm},
+		{Kind: OptionTimestamp, Data: []byte{0, 0, 0, 1, 0, 0, 0, 0}},
+	})
+	if err != nil {
+		t.Fatalf("MarshalOptions: %v", err)
+	}
+	want := []byte{2, 4, 0x05, 0xb4, 4, 2, 8, 10, 0, 0, 0, 1, 0, 0, 0, 0}
+	if !bytes.Equal(b, want) {
+		t.Errorf("MarshalOptions = %x, want %x", b, want)
+	}
+
+	b, err = MarshalOptions([]Option{{Kind: OptionMSS, Data: []byte{0x05, 0xb4}}, {Kind: OptionNOP}, {Kind: OptionWScale, Data: []byte{7}}, {Kind: OptionSACKPerm}})
+	if want := []byte{2, 4, 0x05, 0xb4, 1, 3, 3, 7, 4, 2, 0, 0}; err != nil || !bytes.Equal(b, want) {
+		t.Errorf("MarshalOptions = %x, %v; want %x padded with EOL", b, err, want)
+	}
+
+	tooLong := []Option{{Kind: OptionFastOpen, Data: make([]byte, 16)}, {Kind: OptionFastOpen, Data: make([]byte, 16)}, {Kind: OptionMSS, Data: []byte{0x05, 0xb4}}, {Kind: OptionNOP}}
+	if _, err := MarshalOptions(tooLong[:3]); err != nil {
+		t.Errorf("exactly 40 bytes: err = %v", err)
+	}
+	if _, err := MarshalOptions(tooLong); err != ErrOptionsTooLong {
+		t.Errorf("41 bytes: err = %v, want ErrOptionsTooLong", err)
+	}
+	if _, err := MarshalOptions([]Option{{Kind: OptionMSS, Length: 3, Data: []byte{0x05, 0xb4}}}); !errors.Is(err, ErrBadOptionLength) {
+		t.Errorf("mismatched Length: err = %v, want ErrBadOptionLength", err)
+	}
+}
+
+func BenchmarkOptionsStandard(b *testing.B) {
+
+	p := buildPacket(46926, 443, 1, 1, FlagACK, 64240)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Options(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOptionsWithTimestamps(b *testing.B) {
+
+	opts, _ := MarshalOptions([]Option{
+		{Kind: OptionNOP}, {Kind: OptionNOP},
+		{Kind: OptionTimestamp, Data: []byte{0, 0, 0, 1, 0, 0, 0, 0}},
+	})
+	p := buildPacket(46926, 443, 1, 1, FlagACK, 64240)
+	p.Header = append(p.Header, opts...)
+	p.Header[12] = 8 << 4
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Options(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRTTSampleKey(t *testing.T) {
+
+	p := withOptions(t, Option{Kind: OptionNOP}, Option{Kind: OptionNOP},
+		Option{Kind: OptionTimestamp, Data: []byte{0x12, 0x34, 0x56, 0x78, 0, 0, 0, 9}})
+	if key, ok := p.RTTSampleKey(); !ok || key != 0x12345678 {
+		t.Errorf("RTTSampleKey() = %#x, %v; want 0x12345678, true", key, ok)
+	}
+	if _, ok := buildPacket(46926, 443, 1, 1, FlagACK, 64240).RTTSampleKey(); ok {
+		t.Error("segment without timestamps gave a key")
+	}
+}
+
+func TestIsSMCRendezvous(t *testing.T) {
+
+	smcr := []byte{0xe2, 0xd4, 0xc3, 0xd9} // "SMCR" in EBCDIC
+	for _, kind := range []uint8{OptionExp1, OptionExp2} {
+		p := withOptions(t, append(linuxSYNOptions(1460), Option{Kind: kind, Data: smcr})...)
+		if !p.IsSMCRendezvous() {
+			t.Errorf("kind %d: SMC-R option not recognised", kind)
+		}
+	}
+
+	if withOptions(t, linuxSYNOptions(1460)...).IsSMCRendezvous() {
+		t.Error("plain Linux SYN reported as SMC-R")
+	}
+	if withOptions(t, Option{Kind: OptionExp1, Data: []byte{0xf9, 0x89}}).IsSMCRendezvous() {
+		t.Error("experimental option with another ExID reported as SMC-R")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// MaxHeaderLen is the largest header, in bytes, NewPacket and ReadPacket
+// accept. The 4-bit data offset already caps the header at 60 bytes; the
+// bound is kept explicit so it is checked rather than assumed. Lowering it
+// rejects headers with options; below 20 every header is rejected.
+var MaxHeaderLen = 60
+
+// NewPacket validates b and returns a Packet over its header bytes. Any
+// bytes past the data offset are payload and are not part of Header; b is
+// not copied.
+func NewPacket(b []byte) (*Packet, error) {
+
+	p := &Packet{}
+	if err := ParseInto(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseInto is NewPacket for an existing Packet, which saves allocating one
+// per header in a tight loop. p is left unchanged when b is rejected.
+func ParseInto(p *Packet, b []byte) error {
+
+	q := Packet{Header: b}
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	n := q.headerLen()
+	if n > MaxHeaderLen {
+		return ErrBadDataOffset
+	}
+	p.Header = b[:n]
+	return nil
+}
+
+// ReadPacket reads one header, options included, from r.
+func ReadPacket(r io.Reader) (*Packet, error) {
+
+	h := make([]byte, 20)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	n := int(h[12]>>4) * 4
+	if n < 20 || n > MaxHeaderLen {
+		return nil, ErrBadDataOffset
+	}
+	if n > 20 {
+		h = append(h, make([]byte, n-20)...)
+		if _, err := io.ReadFull(r, h[20:]); err != nil {
+			return nil, err
+		}
+	}
+	return &Packet{Header: h}, nil
+}
+
+// Validate checks that the fixed header is present and that the data offset
+// is in range and covered by the header bytes.
+func (p *Packet) Validate() error {
+
+	if len(p.Header) < 20 {
+		return ErrShortHeader
+	}
+	if p.DO() < 5 {
+		return ErrBadDataOffset
+	}
+	if len(p.Header) < p.headerLen() {
+		return ErrShortHeader
+	}
+	return nil
+}
+
+// ParseCheck decodes every field and every option, returning the first
+// error found. Unlike Validate it walks the options, with strict lengths.
+func (p *Packet) ParseCheck() error {
+
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	_, _, err := p.OptionsWith(ParseOptions{StrictLengths: true})
+	return err
+}
+
+// TimestampedPacket a Packet together with the time it was received.
+type TimestampedPacket struct {
+	Packet *Packet
+	RxTime time.Time
+}
+
+// NewTimestamped parses b with NewPacket and attaches the receive time t.
+func NewTimestamped(b []byte, t time.Time) (*TimestampedPacket, error) {
+
+	p, err := NewPacket(b)
+	if err != nil {
+		return nil, err
+	}
+	return &TimestampedPacket{Packet: p, RxTime: t}, nil
+}
+
+// ParseRing parses the header starting at offset in buf, such as a packet
+// ring shared with the kernel, and returns the index just past it, where the
+// next packed header would start. The Packet refers into buf; nothing is
+// copied.
+func ParseRing(buf []byte, offset int) (*Packet, int, error) {
+
+	if offset < 0 || offset > len(buf) {
+		return nil, 0, ErrShortHeader
+	}
+	p, err := NewPacket(buf[offset:])
+	if err != nil {
+		return nil, 0, err
+	}
+	return p, offset + len(p.Header), nil
+}
+
+// ParseAfterPrefix skips prefixLen bytes of encapsulation, such as a proxy
+// or capture shim, and parses the TCP header that follows with NewPacket.
+func ParseAfterPrefix(b []byte, prefixLen int) (*Packet, error) {
+
+	if prefixLen < 0 || prefixLen > len(b) {
+		return nil, ErrShortHeader
+	}
+	return NewPacket(b[prefixLen:])
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseCheck(t *testing.T) {
+
+	p := withOptions(t,
+		Option{Kind: OptionMSS, Data: []byte{0x05, 0xb4}},
+		Option{Kind: OptionSACKPerm},
+		Option{Kind: OptionNOP},
+		Option{Kind: OptionWScale, Data: []byte{7}},
+	)
+	if err := p.ParseCheck(); err != nil {
+		t.Errorf("valid header: ParseCheck() = %v", err)
+	}
+
+	// An MSS option whose length byte runs past the options region.
+	p = withOptions(t, Option{Kind: OptionNOP}, Option{Kind: OptionNOP}, Option{Kind: OptionMSS, Data: []byte{0x05, 0xb4}})
+	p.Header[23] = 40
+	if err := p.ParseCheck(); err != ErrTruncatedOption {
+		t.Errorf("truncated option: ParseCheck() = %v, want ErrTruncatedOption", err)
+	}
+}
+
+// offsetHeader returns an option-less SYN padded with NOPs to words 32-bit words.
+func offsetHeader(words uint8) []byte {
+
+	p := buildPacket(46926, 443, 1, 0, FlagSYN, 64240)
+	for len(p.Header) < int(words)*4 {
+		p.Header = append(p.Header, OptionNOP)
+	}
+	p.Header[12] = words << 4
+	return p.Header
+}
+
+func TestMaxHeaderLen(t *testing.T) {
+
+	b := offsetHeader(15)
+	if p, err := NewPacket(b); err != nil || len(p.Header) != 60 {
+		t.Errorf("offset 15: NewPacket = %v; want a 60-byte header", err)
+	}
+	if p, err := ReadPacket(bytes.NewReader(b)); err != nil || len(p.Header) != 60 {
+		t.Errorf("offset 15: ReadPacket = %v; want a 60-byte header", err)
+	}
+
+	defer func(n int) { MaxHeaderLen = n }(MaxHeaderLen)
+	for _, max := range []int{40, 10} {
+		MaxHeaderLen = max
+		if _, err := NewPacket(b); err != ErrBadDataOffset {
+			t.Errorf("MaxHeaderLen %d: NewPacket err = %v, want ErrBadDataOffset", max, err)
+		}
+		if _, err := ReadPacket(bytes.NewReader(b)); err != ErrBadDataOffset {
+			t.Errorf("MaxHeaderLen %d: ReadPacket err = %v, want ErrBadDataOffset", max, err)
+		}
+	}
+}
+
+func TestNewTimestamped(t *testing.T) {
+
+	at := time.Unix(1700000000, 123456789)
+	tp, err := NewTimestamped(buildPacket(46926, 443, 2974196833, 0, FlagSYN, 64240).Header, at)
+	if err != nil {
+		t.Fatalf("NewTimestamped: %v", err)
+	}
+	if !tp.RxTime.Equal(at) {
+		t.Errorf("RxTime = %v, want %v", tp.RxTime, at)
+	}
+	if got := tp.Packet.SequenceNumber(); got != 2974196833 {
+		t.Errorf("SequenceNumber() = %d, want 2974196833", got)
+	}
+
+	if _, err := NewTimestamped(sampleHeader()[:10], at); err != ErrShortHeader {
+		t.Errorf("short header: err = %v, want ErrShortHeader", err)
+	}
+}
+
+func BenchmarkNewPacket(b *testing.B) {
+
+	h := buildPacket(46926, 443, 1, 1, FlagACK, 64240).Header
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewPacket(h); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+
+	h := buildPacket(46926, 443, 1, 1, FlagACK, 64240).Header
+	var p Packet
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ParseInto(&p, h); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseRing(t *testing.T) {
+
+	first := withOptions(t, linuxSYNOptions(1460)...)
+	second := buildPacket(443, 46926, 5000, 2974196834, FlagSYN|FlagACK, 65160)
+	buf := append(append([]byte{}, first.Header...), second.Header...)
+
+	p, next, err := ParseRing(buf, 0)
+	if err != nil || next != 40 || !bytes.Equal(p.Header, first.Header) {
+		t.Fatalf("first: ParseRing = %x, %d, %v; want the 40-byte SYN and 40", p, next, err)
+	}
+	p, next, err = ParseRing(buf, next)
+	if err != nil || next != 60 || p.SourcePort() != 443 || p.AckNumber() != 2974196834 {
+		t.Fatalf("second: ParseRing = %x, %d, %v; want the SYN-ACK and 60", p, next, err)
+	}
+	buf[40] = 0x99
+	if p.Header[0] != 0x99 {
+		t.Error("Packet does not refer into buf")
+	}
+
+	if _, _, err := ParseRing(buf, next); err != ErrShortHeader {
+		t.Errorf("at the end: err = %v, want ErrShortHeader", err)
+	}
+	if _, _, err := ParseRing(buf, -1); err != ErrShortHeader {
+		t.Errorf("offset -1: err = %v, want ErrShortHeader", err)
+	}
+}
+
+func TestParseAfterPrefix(t *testing.T) {
+
+	syn := withOptions(t, linuxSYNOptions(1460)...)
+	prefix := bytes.Repeat([]byte{0xee}, 12)
+	b := append(append(prefix, syn.Header...), "payload"...)
+
+	p, err := ParseAfterPrefix(b, 12)
+	if err != nil {
+		t.Fatalf("ParseAfterPrefix: %v", err)
+	}
+	if !bytes.Equal(p.Header, syn.Header) {
+		t.Errorf("header = %x, want %x", p.Header, syn.Header)
+	}
+
+	if _, err := ParseAfterPrefix(b[:12], 12); err != ErrShortHeader {
+		t.Errorf("prefix only: err = %v, want ErrShortHeader", err)
+	}
+	if _, err := ParseAfterPrefix(b, len(b)+1); err != ErrShortHeader {
+		t.Errorf("prefix past the end: err = %v, want ErrShortHeader", err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrNilPacket is returned when a Segment has no Packet.
+var ErrNilPacket = errors.New("tcp: segment without packet")
+
+// Gap a range of sequence numbers missing from a stream, End exclusive.
+type Gap struct {
+	Start uint32
+	End   uint32
+}
+
+// ReassembleStream orders the segments of one direction by sequence number
+// and concatenates their payloads. Missing ranges are returned as gaps and
+// left out of the stream. Where segments overlap, the bytes of the first
+// one in sequence order (then input order) are kept.
+func ReassembleStream(segments []Segment) ([]byte, []Gap, error) {
+
+	if len(segments) == 0 {
+		return nil, nil, nil
+	}
+	for _, s := range segments {
+		if s.Packet == nil {
+			return nil, nil, ErrNilPacket
+		}
+	}
+
+	base := segments[0].Packet.DataStartSeq()
+	for _, s := range segments[1:] {
+		if seq := s.Packet.DataStartSeq(); seqLT(seq, base) {
+			base = seq
+		}
+	}
+
+	sorted := make([]Segment, len(segments))
+	copy(sorted, segments)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Packet.DataStartSeq()-base < sorted[j].Packet.DataStartSeq()-base
+	})
+
+	var stream []byte
+	var gaps []Gap
+	var next uint32 // offset from base of the next byte wanted
+	for _, s := range sorted {
+		start := s.Packet.DataStartSeq() - base
+		end := start + uint32(len(s.Payload))
+		if start > next {
+			gaps = append(gaps, Gap{Start: base + next, End: base + start})
+			next = start
+		}
+		if end > next {
+			stream = append(stream, s.Payload[next-start:]...)
+			next = end
+		}
+	}
+
+	return stream, gaps, nil
+}
+
+// Reassembler buffers out-of-order data of one direction and hands back
+// bytes as soon as they are contiguous. It is the stateful counterpart of
+// ReassembleStream.
+type Reassembler struct {
+	next    uint32
+	pending []pendingData
+}
+
+type pendingData struct {
+	seq  uint32
+	data []byte
+}
+
+// NewReassembler returns a Reassembler expecting nextSeq as its first byte.
+func NewReassembler(nextSeq uint32) *Reassembler {
+
+	return &Reassembler{next: nextSeq}
+}
+
+// Insert adds data starting at seq and returns the bytes that became
+// contiguous, in order. Bytes already delivered are dropped, so duplicates
+// and overlaps deliver nothing twice; data is copied if it has to wait.
+func (g *Reassembler) Insert(seq uint32, data []byte) (delivered []byte) {
+
+	seq, data = g.trim(seq, data)
+	if len(data) == 0 {
+		return nil
+	}
+	if seq != g.next {
+		g.pending = append(g.pending, pendingData{seq: seq, data: append([]byte(nil), data...)})
+		return nil
+	}
+
+	delivered = append(delivered, data...)
+	g.next += uint32(len(data))
+
+	for progress := true; progress; {
+		progress = false
+		kept := g.pending[:0]
+		for _, pd := range g.pending {
+			s, d := g.trim(pd.seq, pd.data)
+			switch {
+			case len(d) == 0:
+			case s == g.next:
+				delivered = append(delivered, d...)
+				g.next += uint32(len(d))
+				progress = true
+			default:
+				kept = append(kept, pendingData{seq: s, data: d})
+			}
+		}
+		g.pending = kept
+	}
+	return delivered
+}
+
+// trim drops the part of data that comes before the next expected byte.
+func (g *Reassembler) trim(seq uint32, data []byte) (uint32, []byte) {
+
+	if !seqLT(seq, g.next) {
+		return seq, data
+	}
+	skip := g.next - seq
+	if skip >= uint32(len(data)) {
+		return g.next, nil
+	}
+	return g.next, data[skip:]
+}
@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+// dataSegment returns a data segment carrying payload at seq.
+func dataSegment(seq uint32, payload string) Segment {
+
+	return Segment{Packet: buildPacket(46926, 443, seq, 1, FlagPSH|FlagACK, 64240), Payload: []byte(payload)}
+}
+
+func TestReassembleStream(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		segments []Segment
+		stream   string
+		gaps     []Gap
+	}{
+		{
+			name:     "in order",
+			segments: []Segment{dataSegment(100, "hello "), dataSegment(106, "world")},
+			stream:   "hello world",
+		},
+		{
+			name:     "out of order",
+			segments: []Segment{dataSegment(106, "world"), dataSegment(100, "hello ")},
+			stream:   "hello world",
+		},
+		{
+			name:     "gap",
+			segments: []Segment{dataSegment(100, "hello"), dataSegment(110, "world")},
+			stream:   "helloworld",
+			gaps:     []Gap{{Start: 105, End: 110}},
+		},
+		{
+			name:     "overlap",
+			segments: []Segment{dataSegment(100, "hello"), dataSegment(103, "LOWORLD"), dataSegment(106, "xx")},
+			stream:   "helloWORLD",
+		},
+		{
+			name:     "across the wrap",
+			segments: []Segment{dataSegment(0, "world"), dataSegment(0xfffffffa, "hello ")},
+			stream:   "hello world",
+		},
+	}
+	for _, tt := range tests {
+		stream, gaps, err := ReassembleStream(tt.segments)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(stream) != tt.stream {
+			t.Errorf("%s: stream = %q, want %q", tt.name, stream, tt.stream)
+		}
+		if len(gaps) != len(tt.gaps) {
+			t.Errorf("%s: gaps = %v, want %v", tt.name, gaps, tt.gaps)
+			continue
+		}
+		for i := range gaps {
+			if gaps[i] != tt.gaps[i] {
+				t.Errorf("%s: gaps = %v, want %v", tt.name, gaps, tt.gaps)
+			}
+		}
+	}
+
+	if _, _, err := ReassembleStream([]Segment{{}}); err != ErrNilPacket {
+		t.Errorf("nil packet: err = %v, want ErrNilPacket", err)
+	}
+}
+
+func TestReassemblerInsert(t *testing.T) {
+
+	g := NewReassembler(100)
+	steps := []struct {
+		seq  uint32
+		data string
+		want string
+	}{
+		{105, "world", ""},            // out of order: held
+		{110, "!", ""},                // still waiting for 100
+		{100, "hello", "helloworld!"}, // fills the hole, releases the rest
+		{100, "hello", ""},            // duplicate
+		{109, "d!XYZ", "XYZ"},         // overlap: only the new tail
+		{115, "tail", ""},             // gap at 114
+		{114, "-", "-tail"},
+	}
+	for i, s := range steps {
+		if got := string(g.Insert(s.seq, []byte(s.data))); got != s.want {
+			t.Errorf("step %d: Insert(%d, %q) = %q, want %q", i, s.seq, s.data, got, s.want)
+		}
+	}
+}
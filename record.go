@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"hash/fnv"
+)
+
+// Record a flat copy of every header field, tagged for reflection-based
+// codecs such as encoding/json and msgpack.
+type Record struct {
+	SourcePort      uint16 `json:"src_port" msgpack:"src_port"`
+	DestinationPort uint16 `json:"dst_port" msgpack:"dst_port"`
+	SequenceNumber  uint32 `json:"seq" msgpack:"seq"`
+	AckNumber       uint32 `json:"ack" msgpack:"ack"`
+	DataOffset      uint8  `json:"data_offset" msgpack:"data_offset"`
+	Reserved        uint8  `json:"reserved" msgpack:"reserved"`
+	Flags           uint16 `json:"flags" msgpack:"flags"`
+	Window          uint16 `json:"window" msgpack:"window"`
+	Checksum        uint16 `json:"checksum" msgpack:"checksum"`
+	UrgentPointer   uint16 `json:"urgent_pointer" msgpack:"urgent_pointer"`
+	Options         []byte `json:"options,omitempty" msgpack:"options,omitempty"`
+}
+
+// ToRecord copies the header into a Record. Flags holds the 9 flag bits,
+// NS included; Options holds the raw options region.
+func (p *Packet) ToRecord() Record {
+
+	reserved, _ := p.ReservedAndNS()
+	r := Record{
+		SourcePort:      p.SourcePort(),
+		DestinationPort: p.DestinationPort(),
+		SequenceNumber:  p.SequenceNumber(),
+		AckNumber:       p.AckNumber(),
+		DataOffset:      p.DO(),
+		Reserved:        reserved,
+		Flags:           p.flagBits(),
+		Window:          p.Window(),
+		Checksum:        p.Checksum(),
+		UrgentPointer:   p.UrgentPointer(),
+	}
+	if h := p.headerBytes(); len(h) > 20 {
+		r.Options = append([]byte(nil), h[20:]...)
+	}
+	return r
+}
+
+// DeltaFrom returns the fields of p that differ from baseline, keyed by
+// their Record JSON names and holding p's values. A change anywhere in the
+// options region reports all of p's options under "options".
+func (p *Packet) DeltaFrom(baseline *Packet) map[string]interface{} {
+
+	a, b := p.ToRecord(), baseline.ToRecord()
+	delta := make(map[string]interface{})
+	if a.SourcePort != b.SourcePort {
+		delta["src_port"] = a.SourcePort
+	}
+	if a.DestinationPort != b.DestinationPort {
+		delta["dst_port"] = a.DestinationPort
+	}
+	if a.SequenceNumber != b.SequenceNumber {
+		delta["seq"] = a.SequenceNumber
+	}
+	if a.AckNumber != b.AckNumber {
+		delta["ack"] = a.AckNumber
+	}
+	if a.DataOffset != b.DataOffset {
+		delta["data_offset"] = a.DataOffset
+	}
+	if a.Reserved != b.Reserved {
+		delta["reserved"] = a.Reserved
+	}
+	if a.Flags != b.Flags {
+		delta["flags"] = a.Flags
+	}
+	if a.Window != b.Window {
+		delta["window"] = a.Window
+	}
+	if a.Checksum != b.Checksum {
+		delta["checksum"] = a.Checksum
+	}
+	if a.UrgentPointer != b.UrgentPointer {
+		delta["urgent_pointer"] = a.UrgentPointer
+	}
+	if !bytes.Equal(a.Options, b.Options) {
+		opts, _ := p.Options()
+		delta["options"] = opts
+	}
+	return delta
+}
+
+// Signature64 returns the 64-bit FNV-1a hash of the header, options
+// included. Equal headers hash equal; unequal ones may collide, so it is a
+// pre-filter for dedup and not a substitute for comparing the bytes.
+func (p *Packet) Signature64() uint64 {
+
+	h := fnv.New64a()
+	h.Write(p.headerBytes())
+	return h.Sum64()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToRecordJSON(t *testing.T) {
+
+	p := withOptions(t, Option{Kind: OptionMSS, Data: []byte{0x05, 0xb4}})
+	b, err := json.Marshal(p.ToRecord())
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	want := `{"src_port":46926,"dst_port":443,"seq":2974196833,"ack":0,"data_offset":6,` +
+		`"reserved":0,"flags":2,"window":64240,"checksum":0,"urgent_pointer":0,"options":"AgQFtA=="}`
+	if string(b) != want {
+		t.Errorf("JSON = %s\nwant   %s", b, want)
+	}
+
+	b, err = json.Marshal(buildPacket(46926, 443, 1, 0, FlagSYN, 64240).ToRecord())
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if _, ok := m["options"]; ok {
+		t.Error("option-less header has an options key")
+	}
+}
+
+func TestDeltaFrom(t *testing.T) {
+
+	base := buildPacket(46926, 443, 1000, 1, FlagACK, 64240)
+	p := buildPacket(46926, 443, 2460, 1, FlagACK, 1024)
+	delta := p.DeltaFrom(base)
+	if len(delta) != 2 || delta["seq"] != uint32(2460) || delta["window"] != uint16(1024) {
+		t.Errorf("DeltaFrom = %v, want seq 2460 and window 1024", delta)
+	}
+	if delta := base.DeltaFrom(base); len(delta) != 0 {
+		t.Errorf("DeltaFrom itself = %v, want empty", delta)
+	}
+}
+
+func TestSignature64(t *testing.T) {
+
+	a := withOptions(t, linuxSYNOptions(1460)...)
+	b := withOptions(t, linuxSYNOptions(1460)...)
+	if a.Signature64() != b.Signature64() {
+		t.Errorf("equal headers: %#x != %#x", a.Signature64(), b.Signature64())
+	}
+
+	c := withOptions(t, linuxSYNOptions(1400)...)
+	if a.Signature64() == c.Signature64() {
+		t.Errorf("headers differing in MSS both hash to %#x", a.Signature64())
+	}
+	d := buildPacket(46926, 443, 2974196833, 0, FlagSYN, 64240)
+	e := buildPacket(46926, 443, 2974196834, 0, FlagSYN, 64240)
+	if d.Signature64() == e.Signature64() {
+		t.Errorf("headers differing in sequence number both hash to %#x", d.Signature64())
+	}
+
+	withPayload := &Packet{Header: append(append([]byte{}, d.Header...), "data"...)}
+	if withPayload.Signature64() != d.Signature64() {
+		t.Error("bytes past the data offset changed the hash")
+	}
+}
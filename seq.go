@@ -0,0 +1,307 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// SeqTimeline returns the points of a sequence graph for one direction of a
+// flow. Each point holds the capture time and the relative sequence number
+// just past the segment's payload, so the curve climbs by the bytes sent.
+// pkts, times and payloadLens are read in parallel; extra entries in any of
+// them are ignored.
+func SeqTimeline(pkts []*Packet, times []time.Time, payloadLens []int, isn uint32) []struct {
+	T      time.Time
+	RelSeq uint32
+} {
+
+	n := len(pkts)
+	if len(times) < n {
+		n = len(times)
+	}
+	if len(payloadLens) < n {
+		n = len(payloadLens)
+	}
+
+	points := make([]struct {
+		T      time.Time
+		RelSeq uint32
+	}, n)
+	for i := 0; i < n; i++ {
+		points[i].T = times[i]
+		points[i].RelSeq = pkts[i].SequenceNumber() - isn + uint32(payloadLens[i])
+	}
+	return points
+}
+
+// seqLT reports whether a comes before b in sequence space (RFC 1982 serial
+// arithmetic), which stays correct across the 2^32 wrap.
+func seqLT(a, b uint32) bool {
+
+	return int32(a-b) < 0
+}
+
+// seqLEQ reports whether a equals or comes before b in sequence space.
+func seqLEQ(a, b uint32) bool {
+
+	return int32(a-b) <= 0
+}
+
+// GapBefore returns the number of bytes missing between expectedSeq and this
+// segment's sequence number when the segment is ahead of it, Wireshark's
+// "previous segment not captured". It returns false when there is no gap.
+func (p *Packet) GapBefore(expectedSeq uint32) (uint32, bool) {
+
+	seq := p.SequenceNumber()
+	if !seqLT(expectedSeq, seq) {
+		return 0, false
+	}
+	return seq - expectedSeq, true
+}
+
+// NextSeq returns the sequence number following this segment. SYN and FIN
+// each occupy one sequence number on top of the payload.
+func (p *Packet) NextSeq(payloadLen int) uint32 {
+
+	next := p.SequenceNumber() + uint32(payloadLen)
+	if p.hasFlag(FlagSYN) {
+		next++
+	}
+	if p.hasFlag(FlagFIN) {
+		next++
+	}
+	return next
+}
+
+// ExpectedAck returns the acknowledgment number a receiver should send
+// after accepting this segment.
+func (p *Packet) ExpectedAck(payloadLen int) uint32 {
+
+	return p.NextSeq(payloadLen)
+}
+
+// IsRetransmission reports whether a segment with payloadLen bytes starts
+// before expectedSeq, the next new sequence number of its direction, and so
+// resends data already sent.
+func (p *Packet) IsRetransmission(expectedSeq uint32, payloadLen int) bool {
+
+	return payloadLen > 0 && seqLT(p.SequenceNumber(), expectedSeq)
+}
+
+// ContinuityScore returns the fraction of data segments that follow the
+// previous data segment of their direction exactly, with neither a gap
+// (GapBefore) nor a retransmission (IsRetransmission). pkts and
+// payloadLens are read in parallel and in capture order; directions are
+// told apart by ports. A flow with no such pairs scores 1.
+func ContinuityScore(pkts []*Packet, payloadLens []int) float64 {
+
+	next := make(map[[2]uint16]uint32)
+	var pairs, inOrder int
+	for i, p := range pkts {
+		if i >= len(payloadLens) || payloadLens[i] == 0 {
+			continue
+		}
+		dir := [2]uint16{p.SourcePort(), p.DestinationPort()}
+		expected, seen := next[dir]
+		end := p.NextSeq(payloadLens[i])
+		if !seen || seqLT(expected, end) {
+			next[dir] = end
+		}
+		if !seen {
+			continue
+		}
+
+		pairs++
+		if _, gap := p.GapBefore(expected); !gap && !p.IsRetransmission(expected, payloadLens[i]) {
+			inOrder++
+		}
+	}
+
+	if pairs == 0 {
+		return 1
+	}
+	return float64(inOrder) / float64(pairs)
+}
+
+// IsOldAck reports whether curAck moves backwards from prevAck in sequence
+// space, which points to reordering or a spoofed segment.
+func IsOldAck(prevAck, curAck uint32) bool {
+
+	return seqLT(curAck, prevAck)
+}
+
+// seqInWindow reports whether seq falls in [start, start+size), the segment
+// acceptability test of RFC 9293 section 3.10.7.4.
+func seqInWindow(seq, start, size uint32) bool {
+
+	return seq-start < size
+}
+
+// IsGhostRST reports whether p is a RST whose sequence number lies outside
+// any receive window that could start at expectedSeq: not even the largest
+// scaled window, MaxScaledWindow, reaches it, on either side. Such resets
+// are blind off-path injections rather than the near-miss resets the
+// RFC 5961 challenge ACK handles. Only a RST 2^30 or more away from
+// expectedSeq is flagged, so this catches just the grossest injections;
+// one 2^29 away could still land in a legal window and is not reported.
+func (p *Packet) IsGhostRST(expectedSeq uint32) bool {
+
+	if !p.hasFlag(FlagRST) {
+		return false
+	}
+	seq := p.SequenceNumber()
+	return !seqInWindow(seq, expectedSeq, MaxScaledWindow) && !seqInWindow(expectedSeq, seq, MaxScaledWindow)
+}
+
+// SeqPoint a point of a sequence graph. Interpolated marks points that were
+// filled in by InterpolateGapsMarked rather than observed.
+type SeqPoint struct {
+	T            time.Time
+	Seq          uint32
+	Interpolated bool
+}
+
+// maxInterpolated caps the points InterpolateGapsMarked adds to a single hole.
+const maxInterpolated = 1000
+
+// InterpolateGaps returns points with extra points across each hole: a
+// step between two points more than twice the median step. Filler points
+// are spaced by the median step with linearly interpolated sequence
+// numbers, so a plot can draw the hole distinctly instead of as one
+// straight line. The result has the input's type, which cannot carry a
+// mark; InterpolateGapsMarked returns the same points with the filler
+// marked.
+func InterpolateGaps(points []struct {
+	T   time.Time
+	Seq uint32
+}) []struct {
+	T   time.Time
+	Seq uint32
+} {
+
+	marked := InterpolateGapsMarked(points)
+	out := make([]struct {
+		T   time.Time
+		Seq uint32
+	}, len(marked))
+	for i, pt := range marked {
+		out[i].T, out[i].Seq = pt.T, pt.Seq
+	}
+	return out
+}
+
+// InterpolateGapsMarked is InterpolateGaps with each filler point marked
+// Interpolated.
+func InterpolateGapsMarked(points []struct {
+	T   time.Time
+	Seq uint32
+}) []SeqPoint {
+
+	out := make([]SeqPoint, 0, len(points))
+	if len(points) == 0 {
+		return out
+	}
+
+	var steps []time.Duration
+	for i := 1; i < len(points); i++ {
+		if d := points[i].T.Sub(points[i-1].T); d > 0 {
+			steps = append(steps, d)
+		}
+	}
+	var median time.Duration
+	if len(steps) > 0 {
+		sort.Slice(steps, func(i, j int) bool { return steps[i] < steps[j] })
+		median = steps[len(steps)/2]
+	}
+
+	out = append(out, SeqPoint{T: points[0].T, Seq: points[0].Seq})
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		dt := cur.T.Sub(prev.T)
+		if median > 0 && dt > 2*median {
+			dseq := float64(int32(cur.Seq - prev.Seq))
+			for k := 1; k <= maxInterpolated; k++ {
+				step := time.Duration(k) * median
+				if step >= dt {
+					break
+				}
+				seq := prev.Seq + uint32(int32(dseq*float64(step)/float64(dt)))
+				out = append(out, SeqPoint{T: prev.T.Add(step), Seq: seq, Interpolated: true})
+			}
+		}
+		out = append(out, SeqPoint{T: cur.T, Seq: cur.Seq})
+	}
+	return out
+}
+
+// DataStartSeq returns the sequence number of the segment's first payload
+// byte. Options live in the header, not in the byte stream, so they take
+// no sequence space and the payload starts at the sequence number itself,
+// except on a SYN: the SYN flag occupies that number and data carried on a
+// SYN starts one past it.
+func (p *Packet) DataStartSeq() uint32 {
+
+	if p.hasFlag(FlagSYN) {
+		return p.SequenceNumber() + 1
+	}
+	return p.SequenceNumber()
+}
+
+// CoalesceSACK merges overlapping and adjacent SACK blocks, each
+// [left edge, right edge), into the fewest blocks, ordered by sequence.
+// Blocks are compared in sequence space, so ranges across the 2^32 wrap
+// merge correctly as long as they span less than 2^31 in total.
+func CoalesceSACK(blocks [][2]uint32) [][2]uint32 {
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	base := blocks[0][0]
+	for _, b := range blocks[1:] {
+		if seqLT(b[0], base) {
+			base = b[0]
+		}
+	}
+
+	sorted := make([][2]uint32, len(blocks))
+	copy(sorted, blocks)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0]-base < sorted[j][0]-base })
+
+	merged := [][2]uint32{sorted[0]}
+	for _, b := range sorted[1:] {
+		cur := &merged[len(merged)-1]
+		if seqLEQ(b[0], cur[1]) {
+			if seqLT(cur[1], b[1]) {
+				cur[1] = b[1]
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+// AggregateSACK collects the SACK blocks of every ACK in one direction and
+// coalesces them, giving every range the receiver reported holding out of
+// order during the capture.
+func AggregateSACK(acks []*Packet) [][2]uint32 {
+
+	var all [][2]uint32
+	for _, a := range acks {
+		if blocks, ok := a.SACKBlocks(); ok {
+			all = append(all, blocks...)
+		}
+	}
+	return CoalesceSACK(all)
+}
+
+// PAWSReject reports whether a receiver that last recorded prevTSval (its
+// TS.Recent) would drop a segment carrying curTSval under PAWS, RFC 7323
+// section 5.3: the timestamp is older, compared modulo 2^32 as sequence
+// numbers are. The rule that expires TS.Recent after 24 idle days needs
+// timing this function does not have and is not applied.
+func PAWSReject(prevTSval, curTSval uint32) bool {
+
+	return seqLT(curTSval, prevTSval)
+}
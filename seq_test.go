@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestSeqTimeline(t *testing.T) {
+
+	const isn = 1000
+	t0 := time.Unix(1700000000, 0)
+	pkts := []*Packet{
+		buildPacket(46926, 443, isn, 0, FlagSYN, 64240),
+		buildPacket(46926, 443, isn+1, 5001, FlagACK, 64240),
+		buildPacket(46926, 443, isn+1, 5001, FlagPSH|FlagACK, 64240),
+		buildPacket(46926, 443, isn+101, 5001, FlagPSH|FlagACK, 64240),
+	}
+	times := []time.Time{t0, t0.Add(10 * time.Millisecond), t0.Add(11 * time.Millisecond), t0.Add(12 * time.Millisecond)}
+	lens := []int{0, 0, 100, 50}
+
+	got := SeqTimeline(pkts, times, lens, isn)
+	want := []uint32{0, 1, 101, 151}
+	if len(got) != len(want) {
+		t.Fatalf("got %d points, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].T.Equal(times[i]) || got[i].RelSeq != want[i] {
+			t.Errorf("point %d = %v, %d; want %v, %d", i, got[i].T, got[i].RelSeq, times[i], want[i])
+		}
+	}
+
+	if got := SeqTimeline(pkts, times[:2], lens, isn); len(got) != 2 {
+		t.Errorf("with 2 times: got %d points, want 2", len(got))
+	}
+}
+
+func TestGapBefore(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1500, 1, FlagACK, 64240)
+	if gap, ok := p.GapBefore(1000); !ok || gap != 500 {
+		t.Errorf("GapBefore(1000) = %d, %v; want 500, true", gap, ok)
+	}
+	if gap, ok := p.GapBefore(1500); ok {
+		t.Errorf("GapBefore(1500) = %d, true; want no gap", gap)
+	}
+	if gap, ok := p.GapBefore(2000); ok {
+		t.Errorf("GapBefore(2000) = %d, true; want no gap for old data", gap)
+	}
+
+	wrapped := buildPacket(46926, 443, 100, 1, FlagACK, 64240)
+	if gap, ok := wrapped.GapBefore(0xffffffff - 399); !ok || gap != 500 {
+		t.Errorf("across the wrap: GapBefore = %d, %v; want 500, true", gap, ok)
+	}
+}
+
+func TestExpectedAck(t *testing.T) {
+
+	syn := buildPacket(46926, 443, 1000, 0, FlagSYN, 64240)
+	if got := syn.ExpectedAck(0); got != 1001 {
+		t.Errorf("SYN: ExpectedAck(0) = %d, want 1001", got)
+	}
+	data := buildPacket(46926, 443, 1001, 5001, FlagPSH|FlagACK, 64240)
+	if got := data.ExpectedAck(100); got != 1101 {
+		t.Errorf("data: ExpectedAck(100) = %d, want 1101", got)
+	}
+	fin := buildPacket(46926, 443, 1101, 5001, FlagFIN|FlagACK, 64240)
+	if got := fin.ExpectedAck(10); got != 1112 {
+		t.Errorf("FIN with data: ExpectedAck(10) = %d, want 1112", got)
+	}
+}
+
+func TestContinuityScore(t *testing.T) {
+
+	var pkts []*Packet
+	var lens []int
+	for i := uint32(0); i < 5; i++ {
+		pkts = append(pkts, buildPacket(46926, 443, 1001+i*100, 1, FlagACK, 64240))
+		lens = append(lens, 100)
+	}
+	if got := ContinuityScore(pkts, lens); got != 1 {
+		t.Errorf("clean flow: ContinuityScore = %v, want 1", got)
+	}
+
+	// Drop the third segment: one of the three remaining pairs has a gap.
+	gappy := []*Packet{pkts[0], pkts[1], pkts[3], pkts[4]}
+	if got, want := ContinuityScore(gappy, lens[:4]), 2.0/3; got != want {
+		t.Errorf("with a gap: ContinuityScore = %v, want %v", got, want)
+	}
+
+	// Resend the second segment: one of five pairs is a retransmission.
+	resent := append([]*Packet{pkts[0], pkts[1], pkts[1]}, pkts[2:]...)
+	if got, want := ContinuityScore(resent, append(lens, 100)), 0.8; got != want {
+		t.Errorf("with a retransmission: ContinuityScore = %v, want %v", got, want)
+	}
+}
+
+func TestIsOldAck(t *testing.T) {
+
+	tests := []struct {
+		prev, cur uint32
+		want      bool
+	}{
+		{5000, 4000, true},
+		{5000, 5000, false},
+		{5000, 6000, false},
+		{10, 0xfffffff0, true},  // regressed back across the wrap
+		{0xfffffff0, 10, false}, // advanced across the wrap
+	}
+	for _, tt := range tests {
+		if got := IsOldAck(tt.prev, tt.cur); got != tt.want {
+			t.Errorf("IsOldAck(%d, %d) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+		}
+	}
+}
+
+func TestIsGhostRST(t *testing.T) {
+
+	var expected uint32 = 1000
+	tests := []struct {
+		seq  uint32
+		want bool
+	}{
+		{expected, false},
+		{expected + 1<<29, false},
+		{expected - 1<<29, false},
+		{expected + 1<<31, true},
+		{expected + 3<<30, true},
+	}
+	for _, tt := range tests {
+		p := buildPacket(46926, 443, tt.seq, 0, FlagRST, 0)
+		if got := p.IsGhostRST(expected); got != tt.want {
+			t.Errorf("RST seq %d: IsGhostRST(%d) = %v, want %v", tt.seq, expected, got, tt.want)
+		}
+	}
+	if buildPacket(46926, 443, expected+1<<31, 1, FlagACK, 0).IsGhostRST(expected) {
+		t.Error("non-RST flagged")
+	}
+}
+
+func TestInterpolateGaps(t *testing.T) {
+
+	t0 := time.Unix(1700000000, 0)
+	ms := time.Millisecond
+	points := []struct {
+		T   time.Time
+		Seq uint32
+	}{
+		{t0, 0}, {t0.Add(10 * ms), 100}, {t0.Add(20 * ms), 200},
+		{t0.Add(70 * ms), 700}, // a 50ms hole
+		{t0.Add(80 * ms), 800},
+	}
+
+	marked := InterpolateGapsMarked(points)
+	if len(marked) != 9 {
+		t.Fatalf("got %d points, want 9: %v", len(marked), marked)
+	}
+	for i, pt := range marked {
+		filler := i >= 3 && i <= 6
+		if pt.Interpolated != filler {
+			t.Errorf("point %d: Interpolated = %v, want %v", i, pt.Interpolated, filler)
+		}
+		if want := uint32(i * 100); i <= 7 && pt.Seq != want {
+			t.Errorf("point %d: Seq = %d, want %d", i, pt.Seq, want)
+		}
+	}
+
+	plain := InterpolateGaps(points)
+	if len(plain) != len(marked) {
+		t.Fatalf("InterpolateGaps gave %d points, InterpolateGapsMarked %d", len(plain), len(marked))
+	}
+	for i := range plain {
+		if !plain[i].T.Equal(marked[i].T) || plain[i].Seq != marked[i].Seq {
+			t.Errorf("point %d differs: %v vs %v", i, plain[i], marked[i])
+		}
+	}
+
+	if got := InterpolateGaps(points[:3]); len(got) != 3 {
+		t.Errorf("no hole: got %d points, want 3", len(got))
+	}
+}
+
+func TestDataStartSeq(t *testing.T) {
+
+	if got := buildPacket(46926, 443, 1000, 0, FlagSYN, 64240).DataStartSeq(); got != 1001 {
+		t.Errorf("SYN: DataStartSeq = %d, want 1001", got)
+	}
+	if got := withOptions(t, linuxSYNOptions(1460)...).DataStartSeq(); got != 2974196834 {
+		t.Errorf("SYN with options: DataStartSeq = %d, want 2974196834", got)
+	}
+	if got := buildPacket(46926, 443, 1001, 5001, FlagPSH|FlagACK, 64240).DataStartSeq(); got != 1001 {
+		t.Errorf("data: DataStartSeq = %d, want 1001", got)
+	}
+}
+
+func TestCoalesceSACK(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		blocks [][2]uint32
+		want   [][2]uint32
+	}{
+		{"empty", nil, nil},
+		{"overlap", [][2]uint32{{300, 500}, {100, 350}}, [][2]uint32{{100, 500}}},
+		{"adjacent", [][2]uint32{{100, 200}, {200, 300}}, [][2]uint32{{100, 300}}},
+		{"disjoint", [][2]uint32{{500, 600}, {100, 200}}, [][2]uint32{{100, 200}, {500, 600}}},
+		{"contained", [][2]uint32{{100, 600}, {200, 300}}, [][2]uint32{{100, 600}}},
+		{"wrap", [][2]uint32{{100, 300}, {0xffffff00, 0xfffffff0}, {0xfffffff0, 100}}, [][2]uint32{{0xffffff00, 300}}},
+	}
+	for _, tt := range tests {
+		got := CoalesceSACK(tt.blocks)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: CoalesceSACK = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: CoalesceSACK = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAggregateSACK(t *testing.T) {
+
+	sack := func(blocks ...uint32) *Packet {
+		data := make([]byte, 4*len(blocks))
+		for i, b := range blocks {
+			binary.BigEndian.PutUint32(data[4*i:], b)
+		}
+		p := withOptions(t, Option{Kind: OptionNOP}, Option{Kind: OptionNOP}, Option{Kind: OptionSACK, Data: data})
+		p.Header[13] = byte(FlagACK)
+		return p
+	}
+
+	acks := []*Packet{
+		buildPacket(443, 46926, 5001, 1001, FlagACK, 65160),
+		sack(2001, 3001),
+		sack(2001, 4001),
+		sack(2001, 5001, 6001, 7001),
+		sack(2001, 5001, 6001, 8001),
+	}
+	got := AggregateSACK(acks)
+	want := [][2]uint32{{2001, 5001}, {6001, 8001}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("AggregateSACK = %v, want %v", got, want)
+	}
+
+	if got := AggregateSACK(acks[:1]); got != nil {
+		t.Errorf("no SACK blocks: AggregateSACK = %v, want nil", got)
+	}
+}
+
+func TestPAWSReject(t *testing.T) {
+
+	tests := []struct {
+		prev, cur uint32
+		want      bool
+	}{
+		{1000, 999, true},
+		{1000, 1000, false},
+		{1000, 1001, false},
+		{0xfffffff0, 16, false}, // wrapped forward
+		{16, 0xfffffff0, true},
+	}
+	for _, tt := range tests {
+		if got := PAWSReject(tt.prev, tt.cur); got != tt.want {
+			t.Errorf("PAWSReject(%d, %d) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Connection states of RFC 9293 section 3.3.2.
+const (
+	StateClosed      = "CLOSED"
+	StateListen      = "LISTEN"
+	StateSynSent     = "SYN_SENT"
+	StateSynReceived = "SYN_RECEIVED"
+	StateEstablished = "ESTABLISHED"
+	StateFinWait1    = "FIN_WAIT_1"
+	StateFinWait2    = "FIN_WAIT_2"
+	StateCloseWait   = "CLOSE_WAIT"
+	StateClosing     = "CLOSING"
+	StateLastAck     = "LAST_ACK"
+	StateTimeWait    = "TIME_WAIT"
+)
+
+// knownStates holds every valid state name.
+var knownStates = map[string]bool{
+	StateClosed: true, StateListen: true, StateSynSent: true, StateSynReceived: true,
+	StateEstablished: true, StateFinWait1: true, StateFinWait2: true, StateCloseWait: true,
+	StateClosing: true, StateLastAck: true, StateTimeWait: true,
+}
+
+var (
+	// ErrUnknownState is returned for a state name not listed above.
+	ErrUnknownState = errors.New("tcp: unknown connection state")
+	// ErrBadTransition is returned when a packet cannot occur in a state.
+	ErrBadTransition = errors.New("tcp: impossible state transition")
+)
+
+// StateTransition returns the state an endpoint in current moves to when p
+// arrives (RFC 9293 section 3.10.7). A RST closes any state but LISTEN,
+// where it is ignored. A fresh SYN in TIME_WAIT reopens the connection, as
+// RFC 9293 allows. Transitions caused by the endpoint's own segments,
+// such as an active open or close, are given by StateAfterSend.
+func (p *Packet) StateTransition(current string) (next string, err error) {
+
+	if !knownStates[current] {
+		return "", fmt.Errorf("%w: %q", ErrUnknownState, current)
+	}
+
+	syn, ack, fin, rst := p.hasFlag(FlagSYN), p.hasFlag(FlagACK), p.hasFlag(FlagFIN), p.hasFlag(FlagRST)
+
+	switch current {
+	case StateListen:
+		switch {
+		case rst:
+			return StateListen, nil
+		case syn && !ack:
+			return StateSynReceived, nil
+		}
+	case StateClosed:
+		// Anything arriving on a closed connection is answered with a
+		// RST and changes nothing.
+		return StateClosed, nil
+	case StateSynSent:
+		switch {
+		case rst && ack:
+			return StateClosed, nil
+		case rst:
+			// A RST that does not acknowledge our SYN is not acceptable.
+			return StateSynSent, nil
+		case syn && ack:
+			return StateEstablished, nil
+		case syn:
+			return StateSynReceived, nil
+		}
+	default:
+		if rst {
+			return StateClosed, nil
+		}
+		if syn {
+			if current == StateTimeWait && !ack {
+				// A new incarnation of the connection (RFC 9293 section
+				// 3.6.1); whether its ISN is high enough is not checked.
+				return StateSynReceived, nil
+			}
+			break
+		}
+		return receivedInSync(current, ack, fin)
+	}
+
+	return "", fmt.Errorf("%w: %s receiving %s", ErrBadTransition, current, p.tcpdumpFlags())
+}
+
+// receivedInSync handles an ACK or FIN arriving in a synchronized state.
+// The ACK is assumed to cover everything sent, our FIN included.
+func receivedInSync(current string, ack, fin bool) (string, error) {
+
+	if !ack && !fin {
+		return "", fmt.Errorf("%w: %s receiving a segment without ACK", ErrBadTransition, current)
+	}
+
+	switch current {
+	case StateSynReceived:
+		if fin {
+			return StateCloseWait, nil
+		}
+		return StateEstablished, nil
+	case StateEstablished:
+		if fin {
+			return StateCloseWait, nil
+		}
+		return StateEstablished, nil
+	case StateFinWait1:
+		switch {
+		case fin && ack:
+			return StateTimeWait, nil
+		case fin:
+			return StateClosing, nil
+		}
+		return StateFinWait2, nil
+	case StateFinWait2:
+		if fin {
+			return StateTimeWait, nil
+		}
+		return StateFinWait2, nil
+	case StateClosing:
+		return StateTimeWait, nil
+	case StateLastAck:
+		return StateClosed, nil
+	}
+	// CLOSE_WAIT and TIME_WAIT only see retransmissions.
+	return current, nil
+}
+
+// StateAfterSend returns the state an endpoint in current moves to when it
+// sends p: a SYN opens, a FIN closes and a RST aborts. Retransmissions leave
+// the state as it is.
+func (p *Packet) StateAfterSend(current string) (string, error) {
+
+	if !knownStates[current] {
+		return "", fmt.Errorf("%w: %q", ErrUnknownState, current)
+	}
+
+	syn, ack, fin, rst := p.hasFlag(FlagSYN), p.hasFlag(FlagACK), p.hasFlag(FlagFIN), p.hasFlag(FlagRST)
+
+	switch {
+	case rst:
+		return StateClosed, nil
+	case syn && !ack:
+		switch current {
+		case StateClosed, StateListen, StateSynSent:
+			return StateSynSent, nil
+		}
+	case syn:
+		switch current {
+		case StateSynSent, StateSynReceived:
+			return StateSynReceived, nil
+		}
+	case fin:
+		switch current {
+		case StateSynReceived, StateEstablished:
+			return StateFinWait1, nil
+		case StateCloseWait:
+			return StateLastAck, nil
+		case StateFinWait1, StateClosing, StateLastAck:
+			return current, nil
+		}
+	default:
+		switch current {
+		case StateClosed, StateListen, StateSynSent:
+		default:
+			return current, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s sending %s", ErrBadTransition, current, p.tcpdumpFlags())
+}
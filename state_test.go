@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStateWalk(t *testing.T) {
+
+	hs := BuildHandshake(46926, 443, 1000, 5000)
+	syn, synAck, ack := hs[0], hs[1], hs[2]
+	fin := buildPacket(46926, 443, 1001, 5001, FlagFIN|FlagACK, 64240)
+	peerFin := buildPacket(443, 46926, 5001, 1002, FlagFIN|FlagACK, 64240)
+	lastAck := buildPacket(46926, 443, 1002, 5002, FlagACK, 64240)
+
+	// Each step is the packet, whether the client sends it, and the state
+	// the client and server are in afterwards.
+	steps := []struct {
+		p              *Packet
+		clientSends    bool
+		client, server string
+	}{
+		{syn, true, StateSynSent, StateSynReceived},
+		{synAck, false, StateEstablished, StateSynReceived},
+		{ack, true, StateEstablished, StateEstablished},
+		{fin, true, StateFinWait1, StateCloseWait},
+		{peerFin, false, StateTimeWait, StateLastAck},
+		{lastAck, true, StateTimeWait, StateClosed},
+	}
+
+	client, server := StateClosed, StateListen
+	for i, s := range steps {
+		var err1, err2 error
+		if s.clientSends {
+			client, err1 = s.p.StateAfterSend(client)
+			server, err2 = s.p.StateTransition(server)
+		} else {
+			server, err1 = s.p.StateAfterSend(server)
+			client, err2 = s.p.StateTransition(client)
+		}
+		if err1 != nil || err2 != nil {
+			t.Fatalf("step %d: %v, %v", i, err1, err2)
+		}
+		if client != s.client || server != s.server {
+			t.Fatalf("step %d: client %s, server %s; want %s, %s", i, client, server, s.client, s.server)
+		}
+	}
+
+	if next, err := syn.StateTransition(StateTimeWait); err != nil || next != StateSynReceived {
+		t.Errorf("SYN in TIME_WAIT = %s, %v; want SYN_RECEIVED", next, err)
+	}
+	if _, err := synAck.StateTransition(StateEstablished); !errors.Is(err, ErrBadTransition) {
+		t.Errorf("SYN-ACK in ESTABLISHED: err = %v, want ErrBadTransition", err)
+	}
+	if _, err := syn.StateTransition("OPEN"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("unknown state: err = %v, want ErrUnknownState", err)
+	}
+}
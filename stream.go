@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MaxRecordLen is the longest record StreamParser accepts: the largest
+// segment an IPv4 or IPv6 packet without jumbograms can carry.
+const MaxRecordLen = 65535
+
+var (
+	// ErrBadPrefixWidth is returned for a length prefix other than 1, 2 or 4 bytes.
+	ErrBadPrefixWidth = errors.New("tcp: length prefix must be 1, 2 or 4 bytes")
+	// ErrRecordTooLong is returned for a length prefix above MaxRecordLen.
+	ErrRecordTooLong = errors.New("tcp: record longer than a TCP segment")
+)
+
+// StreamParser reads length-prefixed TCP segments from a stream. Each record
+// is a big-endian length of PrefixBytes bytes followed by that many bytes of
+// header and payload.
+type StreamParser struct {
+	PrefixBytes int
+
+	r      io.Reader
+	prefix [4]byte
+}
+
+// NewStreamParser returns a StreamParser reading from r with a length prefix
+// of prefixBytes bytes.
+func NewStreamParser(r io.Reader, prefixBytes int) (*StreamParser, error) {
+
+	switch prefixBytes {
+	case 1, 2, 4:
+	default:
+		return nil, ErrBadPrefixWidth
+	}
+	return &StreamParser{PrefixBytes: prefixBytes, r: r}, nil
+}
+
+// Next reads the next record. It returns io.EOF when the stream ends
+// cleanly between records and io.ErrUnexpectedEOF inside one. A length above
+// MaxRecordLen is rejected with ErrRecordTooLong before anything is
+// allocated; the stream has lost its framing by then and cannot be resumed.
+func (s *StreamParser) Next() (Segment, error) {
+
+	switch s.PrefixBytes {
+	case 1, 2, 4:
+	default:
+		return Segment{}, ErrBadPrefixWidth
+	}
+	prefix := s.prefix[:s.PrefixBytes]
+	if _, err := io.ReadFull(s.r, prefix); err != nil {
+		return Segment{}, err
+	}
+
+	var n int
+	switch s.PrefixBytes {
+	case 1:
+		n = int(prefix[0])
+	case 2:
+		n = int(binary.BigEndian.Uint16(prefix))
+	case 4:
+		n = int(binary.BigEndian.Uint32(prefix))
+	}
+	if n > MaxRecordLen {
+		return Segment{}, ErrRecordTooLong
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Segment{}, err
+	}
+
+	p, err := NewPacket(b)
+	if err != nil {
+		return Segment{}, err
+	}
+	return Segment{Packet: p, Payload: b[len(p.Header):]}, nil
+}
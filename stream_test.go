@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// framed returns each record prefixed with its length in width bytes.
+func framed(width int, records ...[]byte) []byte {
+
+	var b []byte
+	for _, r := range records {
+		var prefix [4]byte
+		switch width {
+		case 1:
+			prefix[0] = byte(len(r))
+		case 2:
+			binary.BigEndian.PutUint16(prefix[:], uint16(len(r)))
+		case 4:
+			binary.BigEndian.PutUint32(prefix[:], uint32(len(r)))
+		}
+		b = append(b, prefix[:width]...)
+		b = append(b, r...)
+	}
+	return b
+}
+
+func TestStreamParser(t *testing.T) {
+
+	syn := buildPacket(46926, 443, 1, 0, FlagSYN, 64240).Header
+	data := append(buildPacket(46926, 443, 2, 1, FlagPSH|FlagACK, 64240).Header, "hello"...)
+
+	for _, width := range []int{2, 4} {
+		sp, err := NewStreamParser(bytes.NewReader(framed(width, syn, data)), width)
+		if err != nil {
+			t.Fatalf("width %d: NewStreamParser: %v", width, err)
+		}
+		s, err := sp.Next()
+		if err != nil || !s.Packet.IsInitialSYN() || len(s.Payload) != 0 {
+			t.Errorf("width %d: first record = %v, %v; want a bare SYN", width, s.Packet, err)
+		}
+		s, err = sp.Next()
+		if err != nil || s.Packet.SequenceNumber() != 2 || string(s.Payload) != "hello" {
+			t.Errorf("width %d: second record = %v, %q, %v; want seq 2 with hello", width, s.Packet, s.Payload, err)
+		}
+		if _, err := sp.Next(); err != io.EOF {
+			t.Errorf("width %d: at end err = %v, want io.EOF", width, err)
+		}
+	}
+
+	cut := framed(2, data)
+	sp, _ := NewStreamParser(bytes.NewReader(cut[:len(cut)-2]), 2)
+	if _, err := sp.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated record: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	if _, err := NewStreamParser(bytes.NewReader(nil), 3); err != ErrBadPrefixWidth {
+		t.Errorf("width 3: err = %v, want ErrBadPrefixWidth", err)
+	}
+	sp, _ = NewStreamParser(bytes.NewReader(framed(2, syn)), 2)
+	sp.PrefixBytes = 8
+	if _, err := sp.Next(); err != ErrBadPrefixWidth {
+		t.Errorf("PrefixBytes changed to 8: err = %v, want ErrBadPrefixWidth", err)
+	}
+}
+
+func TestStreamParserRecordTooLong(t *testing.T) {
+
+	// A hostile 4 GiB length with nothing behind it.
+	sp, _ := NewStreamParser(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}), 4)
+	if _, err := sp.Next(); err != ErrRecordTooLong {
+		t.Errorf("length 0xffffffff: err = %v, want ErrRecordTooLong", err)
+	}
+	sp, _ = NewStreamParser(bytes.NewReader([]byte{0, 1, 0, 0}), 4)
+	if _, err := sp.Next(); err != ErrRecordTooLong {
+		t.Errorf("length 65536: err = %v, want ErrRecordTooLong", err)
+	}
+
+	// The largest allowed length gets as far as reading the record.
+	sp, _ = NewStreamParser(bytes.NewReader([]byte{0, 0, 0xff, 0xff}), 4)
+	if _, err := sp.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("length 65535: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import "time"
+
+// Close types reported in FlowSummary.
+const (
+	CloseFIN  = "fin"
+	CloseRST  = "rst"
+	CloseOpen = "open"
+)
+
+// FlowSummary the top-level report for one connection.
+type FlowSummary struct {
+	TotalBytes   int           // payload bytes in the data direction
+	Retransmits  int           // data segments resending bytes already sent
+	MaxRTT       time.Duration // longest data-to-ACK time, retransmissions excluded
+	HandshakeRTT time.Duration // SYN to SYN-ACK time
+	CloseType    string        // CloseFIN, CloseRST or CloseOpen
+	Duration     time.Duration // first to last packet
+}
+
+// SummarizeFlow builds a FlowSummary from the data segments of one
+// direction and the ACKs of the other. times holds the capture times of
+// segments followed by those of acks; when it is short, the fields that
+// need the missing times are left zero rather than guessed.
+func SummarizeFlow(segments []Segment, acks []*Packet, times []time.Time) FlowSummary {
+
+	var sum FlowSummary
+	segTime := func(i int) (time.Time, bool) {
+		if i < len(times) {
+			return times[i], true
+		}
+		return time.Time{}, false
+	}
+	ackTime := func(j int) (time.Time, bool) { return segTime(len(segments) + j) }
+
+	var first, last time.Time
+	for _, t := range times {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	if !first.IsZero() {
+		sum.Duration = last.Sub(first)
+	}
+
+	sum.CloseType = CloseOpen
+	all := make([]*Packet, 0, len(segments)+len(acks))
+	for _, s := range segments {
+		all = append(all, s.Packet)
+	}
+	all = append(all, acks...)
+	for _, p := range all {
+		if p.hasFlag(FlagRST) {
+			sum.CloseType = CloseRST
+			break
+		}
+		if p.hasFlag(FlagFIN) {
+			sum.CloseType = CloseFIN
+		}
+	}
+
+	var next uint32
+	seen := false
+	for i, s := range segments {
+		n := len(s.Payload)
+		sum.TotalBytes += n
+		retrans := seen && s.Packet.IsRetransmission(next, n)
+		if retrans {
+			sum.Retransmits++
+		}
+		end := s.Packet.NextSeq(n)
+		if !seen || seqLT(next, end) {
+			next, seen = end, true
+		}
+
+		sent, ok := segTime(i)
+		if !ok {
+			continue
+		}
+		if s.Packet.IsInitialSYN() {
+			if rtt, ok := firstAckAfter(sent, end, acks, ackTime, true); ok {
+				sum.HandshakeRTT = rtt
+			}
+		}
+		if n == 0 || retrans {
+			continue
+		}
+		if rtt, ok := firstAckAfter(sent, end, acks, ackTime, false); ok && rtt > sum.MaxRTT {
+			sum.MaxRTT = rtt
+		}
+	}
+
+	return sum
+}
+
+// firstAckAfter returns the time from sent to the first ACK at or after it
+// that covers end. With synAck only SYN-ACKs count.
+func firstAckAfter(sent time.Time, end uint32, acks []*Packet, ackTime func(int) (time.Time, bool), synAck bool) (time.Duration, bool) {
+
+	for j, a := range acks {
+		if !a.hasFlag(FlagACK) || synAck && !a.hasFlag(FlagSYN) || seqLT(a.AckNumber(), end) {
+			continue
+		}
+		at, ok := ackTime(j)
+		if !ok {
+			return 0, false
+		}
+		if !at.Before(sent) {
+			return at.Sub(sent), true
+		}
+	}
+	return 0, false
+}
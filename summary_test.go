@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeFlow(t *testing.T) {
+
+	chunk := strings.Repeat("x", 100)
+	segments := []Segment{
+		{Packet: buildPacket(46926, 443, 1000, 0, FlagSYN, 64240)},
+		dataSegment(1001, chunk),
+		dataSegment(1101, chunk),
+		dataSegment(1101, chunk), // retransmitted
+		{Packet: buildPacket(46926, 443, 1201, 5001, FlagFIN|FlagACK, 64240)},
+	}
+	acks := []*Packet{
+		buildPacket(443, 46926, 5000, 1001, FlagSYN|FlagACK, 65160),
+		buildPacket(443, 46926, 5001, 1101, FlagACK, 65160),
+		buildPacket(443, 46926, 5001, 1201, FlagACK, 65160),
+		buildPacket(443, 46926, 5001, 1202, FlagFIN|FlagACK, 65160),
+	}
+	t0 := time.Unix(1700000000, 0)
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+	times := []time.Time{
+		ms(0), ms(30), ms(31), ms(250), ms(300),
+		ms(20), ms(60), ms(260), ms(310),
+	}
+
+	got := SummarizeFlow(segments, acks, times)
+	want := FlowSummary{
+		TotalBytes:   300,
+		Retransmits:  1,
+		MaxRTT:       229 * time.Millisecond, // the segment later retransmitted
+		HandshakeRTT: 20 * time.Millisecond,
+		CloseType:    CloseFIN,
+		Duration:     310 * time.Millisecond,
+	}
+	if got != want {
+		t.Errorf("SummarizeFlow =\n%+v\nwant\n%+v", got, want)
+	}
+
+	got = SummarizeFlow(segments, acks, nil)
+	if got.MaxRTT != 0 || got.HandshakeRTT != 0 || got.Duration != 0 || got.TotalBytes != 300 {
+		t.Errorf("without times: %+v, want only byte and close counts", got)
+	}
+}
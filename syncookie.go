@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrNoSecret is returned when a SYN cookie is checked without a secret.
+var ErrNoSecret = errors.New("tcp: empty SYN cookie secret")
+
+// synCookieMSS are the MSS values a SYN cookie can encode, in 3 bits.
+var synCookieMSS = [8]uint16{536, 1024, 1200, 1300, 1360, 1400, 1440, 1460}
+
+// SynCookieLifetime is how long after it was issued a SYN cookie is accepted.
+// Cookies carry a one-minute counter, so the check is done to the minute.
+const SynCookieLifetime = 2 * time.Minute
+
+// synCookieNow is the clock used to check cookies.
+var synCookieNow = time.Now
+
+// SynCookie returns the initial sequence number a SYN-cookie responder sends
+// in its SYN-ACK. The top 5 bits hold a minute counter, the next 3 the index
+// of the largest encodable MSS not above mss, and the low 24 bits an
+// HMAC-SHA256 of the connection 4-tuple, the counter and the MSS index under
+// secret, so a client cannot change the MSS it is granted.
+func SynCookie(secret []byte, clientIP, serverIP net.IP, clientPort, serverPort, mss uint16, t time.Time) uint32 {
+
+	idx := 0
+	for i, m := range synCookieMSS {
+		if m <= mss {
+			idx = i
+		}
+	}
+	counter := uint32(t.Unix() / 60)
+	return counter%32<<27 | uint32(idx)<<24 | synCookieMAC(secret, clientIP, serverIP, clientPort, serverPort, counter, uint8(idx))
+}
+
+func synCookieMAC(secret []byte, clientIP, serverIP net.IP, clientPort, serverPort uint16, counter uint32, idx uint8) uint32 {
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(clientIP.To16())
+	mac.Write(serverIP.To16())
+	var b [9]byte
+	binary.BigEndian.PutUint16(b[0:2], clientPort)
+	binary.BigEndian.PutUint16(b[2:4], serverPort)
+	binary.BigEndian.PutUint32(b[4:8], counter)
+	b[8] = idx
+	mac.Write(b[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil)) & 0xffffff
+}
+
+// VerifySynCookie checks the SYN cookie carried by synAck and returns the MSS
+// it encodes. synAck is either the responder's SYN-ACK, whose sequence number
+// is the cookie, or the client's returning ACK, whose acknowledgment number
+// is the cookie plus one. srcIP and dstIP are the addresses of that packet.
+func VerifySynCookie(synAck *Packet, secret []byte, srcIP, dstIP net.IP) (bool, uint16, error) {
+
+	if len(secret) == 0 {
+		return false, 0, ErrNoSecret
+	}
+	if err := synAck.Validate(); err != nil {
+		return false, 0, err
+	}
+
+	cookie := synAck.AckNumber() - 1
+	clientIP, serverIP := srcIP, dstIP
+	clientPort, serverPort := synAck.SourcePort(), synAck.DestinationPort()
+	if synAck.hasFlag(FlagSYN) {
+		cookie = synAck.SequenceNumber()
+		clientIP, serverIP = dstIP, srcIP
+		clientPort, serverPort = serverPort, clientPort
+	}
+
+	now := uint32(synCookieNow().Unix() / 60)
+	for age := uint32(0); age <= uint32(SynCookieLifetime/time.Minute); age++ {
+		counter := now - age
+		if cookie>>27 != counter%32 {
+			continue
+		}
+		idx := uint8(cookie >> 24 & 0x07)
+		if cookie&0xffffff == synCookieMAC(secret, clientIP, serverIP, clientPort, serverPort, counter, idx) {
+			return true, synCookieMSS[idx], nil
+		}
+	}
+	return false, 0, nil
+}
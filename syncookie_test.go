@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSynCookieRoundTrip(t *testing.T) {
+
+	defer func(now func() time.Time) { synCookieNow = now }(synCookieNow)
+	secret := []byte("0123456789abcdef")
+	client, server := testSrcIP, testDstIP
+	issued := time.Unix(1700000000, 0)
+
+	cookie := SynCookie(secret, client, server, 46926, 443, 1400, issued)
+	synAck := buildPacket(443, 46926, cookie, 1001, FlagSYN|FlagACK, 64240)
+	ack := buildPacket(46926, 443, 1001, cookie+1, FlagACK, 64240)
+
+	synCookieNow = func() time.Time { return issued.Add(30 * time.Second) }
+	if ok, mss, err := VerifySynCookie(synAck, secret, server, client); err != nil || !ok || mss != 1400 {
+		t.Errorf("SYN-ACK: VerifySynCookie = %v, %d, %v; want true, 1400, nil", ok, mss, err)
+	}
+	if ok, mss, err := VerifySynCookie(ack, secret, client, server); err != nil || !ok || mss != 1400 {
+		t.Errorf("ACK: VerifySynCookie = %v, %d, %v; want true, 1400, nil", ok, mss, err)
+	}
+	if ok, _, _ := VerifySynCookie(ack, []byte("another secret"), client, server); ok {
+		t.Error("cookie verified under the wrong secret")
+	}
+	if _, _, err := VerifySynCookie(ack, nil, client, server); err != ErrNoSecret {
+		t.Errorf("empty secret: err = %v, want ErrNoSecret", err)
+	}
+
+	synCookieNow = func() time.Time { return issued.Add(SynCookieLifetime + time.Minute) }
+	if ok, _, _ := VerifySynCookie(ack, secret, client, server); ok {
+		t.Error("expired cookie verified")
+	}
+}
+
+func TestSynCookieMSSTampering(t *testing.T) {
+
+	defer func(now func() time.Time) { synCookieNow = now }(synCookieNow)
+	secret := []byte("0123456789abcdef")
+	issued := time.Unix(1700000000, 0)
+	synCookieNow = func() time.Time { return issued }
+
+	cookie := SynCookie(secret, testSrcIP, testDstIP, 46926, 443, 536, issued)
+	for idx := uint32(1); idx < 8; idx++ {
+		forged := cookie&^(0x07<<24) | idx<<24
+		ack := buildPacket(46926, 443, 1001, forged+1, FlagACK, 64240)
+		if ok, mss, _ := VerifySynCookie(ack, secret, testSrcIP, testDstIP); ok {
+			t.Errorf("MSS bits changed to %d: verified with MSS %d", idx, mss)
+		}
+	}
+}
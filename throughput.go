@@ -0,0 +1,79 @@
+package main
+
+import "time"
+
+// DefaultThroughputWindow is used by a ThroughputMeter with no Window set.
+const DefaultThroughputWindow = time.Second
+
+// ThroughputMeter keeps a sliding-window byte rate of data segments.
+// Samples older than Window, measured from the newest sample, are expired.
+type ThroughputMeter struct {
+	Window time.Duration
+
+	samples []throughputSample
+	total   int
+}
+
+type throughputSample struct {
+	bytes int
+	t     time.Time
+}
+
+func (m *ThroughputMeter) window() time.Duration {
+
+	if m.Window <= 0 {
+		return DefaultThroughputWindow
+	}
+	return m.Window
+}
+
+// Add records bytes of payload seen at t. Samples are expected in time order.
+func (m *ThroughputMeter) Add(bytes int, t time.Time) {
+
+	m.samples = append(m.samples, throughputSample{bytes: bytes, t: t})
+	m.total += bytes
+
+	cutoff := t.Add(-m.window())
+	i := 0
+	for i < len(m.samples) && !m.samples[i].t.After(cutoff) {
+		m.total -= m.samples[i].bytes
+		i++
+	}
+	m.samples = m.samples[i:]
+}
+
+// Rate returns the bytes per second over the window.
+func (m *ThroughputMeter) Rate() float64 {
+
+	return float64(m.total) / m.window().Seconds()
+}
+
+// SendRate returns the bytes per second of payload carried by segments, over
+// the time from the first to the last data segment. segments and times are
+// read in parallel; zero-payload segments are ignored. It returns 0 when
+// fewer than two data segments or no time span are available.
+func SendRate(segments []Segment, times []time.Time) float64 {
+
+	var first, last time.Time
+	var total, count int
+	for i, s := range segments {
+		if i >= len(times) {
+			break
+		}
+		if len(s.Payload) == 0 {
+			continue
+		}
+		if count == 0 {
+			first = times[i]
+		}
+		last = times[i]
+		total += len(s.Payload)
+		count++
+	}
+
+	span := last.Sub(first).Seconds()
+	if count < 2 || span <= 0 {
+		return 0
+	}
+	return float64(total) / span
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThroughputMeter(t *testing.T) {
+
+	t0 := time.Unix(1700000000, 0)
+	m := &ThroughputMeter{Window: 2 * time.Second}
+	m.Add(1000, t0)
+	m.Add(1000, t0.Add(500*time.Millisecond))
+	m.Add(2000, t0.Add(time.Second))
+	if got := m.Rate(); got != 2000 {
+		t.Errorf("Rate() = %v, want 2000", got)
+	}
+
+	// At t0+2.5s the samples at t0 and t0+0.5s have expired.
+	m.Add(1000, t0.Add(2500*time.Millisecond))
+	if got := m.Rate(); got != 1500 {
+		t.Errorf("Rate() after expiry = %v, want 1500", got)
+	}
+
+	var d ThroughputMeter
+	d.Add(500, t0)
+	if got := d.Rate(); got != 500 {
+		t.Errorf("default window: Rate() = %v, want 500", got)
+	}
+}
+
+func TestSendRate(t *testing.T) {
+
+	t0 := time.Unix(1700000000, 0)
+	segments := []Segment{dataSegment(1, "0123456789"), {Packet: buildPacket(46926, 443, 11, 1, FlagACK, 64240)}, dataSegment(11, "0123456789")}
+	times := []time.Time{t0, t0.Add(time.Second), t0.Add(2 * time.Second)}
+	if got := SendRate(segments, times); got != 10 {
+		t.Errorf("SendRate = %v, want 10 bytes/s", got)
+	}
+	if got := SendRate(segments[:1], times); got != 0 {
+		t.Errorf("one data segment: SendRate = %v, want 0", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import "encoding/binary"
+
+// tlsReader reads big-endian fields from a byte slice and remembers whether
+// it ran out of data, so parsing code does not check at every step.
+type tlsReader struct {
+	b  []byte
+	ok bool
+}
+
+func (r *tlsReader) bytes(n int) []byte {
+
+	if !r.ok || n > len(r.b) {
+		r.ok = false
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *tlsReader) u8() int {
+
+	if b := r.bytes(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *tlsReader) u16() int {
+
+	if b := r.bytes(2); b != nil {
+		return int(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *tlsReader) u24() int {
+
+	if b := r.bytes(3); b != nil {
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	}
+	return 0
+}
+
+// ExtractSNI returns the server name from the Server Name Indication
+// extension (RFC 6066) of a TLS ClientHello at the start of payload.
+// Non-TLS, truncated or malformed payloads, and ClientHellos without the
+// extension, return false. The ClientHello must fit in the first record.
+func ExtractSNI(payload []byte) (string, bool) {
+
+	r := &tlsReader{b: payload, ok: true}
+	if r.u8() != 0x16 { // handshake record
+		return "", false
+	}
+	r.bytes(2) // record version
+	record := &tlsReader{b: r.bytes(r.u16()), ok: r.ok}
+
+	if record.u8() != 0x01 { // ClientHello
+		return "", false
+	}
+	hello := &tlsReader{b: record.bytes(record.u24()), ok: record.ok}
+	hello.bytes(2 + 32) // client version, random
+	hello.bytes(hello.u8())
+	hello.bytes(hello.u16())
+	hello.bytes(hello.u8())
+	exts := &tlsReader{b: hello.bytes(hello.u16()), ok: hello.ok}
+
+	for exts.ok && len(exts.b) > 0 {
+		typ := exts.u16()
+		data := exts.bytes(exts.u16())
+		if !exts.ok || typ != 0 { // server_name
+			continue
+		}
+		list := &tlsReader{b: data, ok: true}
+		names := &tlsReader{b: list.bytes(list.u16()), ok: list.ok}
+		for names.ok && len(names.b) > 0 {
+			nameType := names.u8()
+			name := names.bytes(names.u16())
+			if names.ok && nameType == 0 && len(name) > 0 { // host_name
+				return string(name), true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// clientHello captures the first TLS record crypto/tls sends when dialing
+// serverName.
+func clientHello(t *testing.T, serverName string) []byte {
+
+	t.Helper()
+	c, s := net.Pipe()
+	defer s.Close()
+	go func() {
+		// Without a name there is nothing to verify against; the
+		// handshake never gets that far anyway.
+		cfg := &tls.Config{ServerName: serverName, InsecureSkipVerify: serverName == ""}
+		tls.Client(c, cfg).Handshake()
+		c.Close()
+	}()
+
+	record := make([]byte, 5)
+	if _, err := io.ReadFull(s, record); err != nil {
+		t.Fatalf("reading record header: %v", err)
+	}
+	record = append(record, make([]byte, binary.BigEndian.Uint16(record[3:5]))...)
+	if _, err := io.ReadFull(s, record[5:]); err != nil {
+		t.Fatalf("reading ClientHello: %v", err)
+	}
+	return record
+}
+
+func TestExtractSNI(t *testing.T) {
+
+	hello := clientHello(t, "example.com")
+	if name, ok := ExtractSNI(hello); !ok || name != "example.com" {
+		t.Errorf("ExtractSNI = %q, %v; want example.com, true", name, ok)
+	}
+	if _, ok := ExtractSNI(clientHello(t, "")); ok {
+		t.Error("ClientHello without SNI reported a name")
+	}
+
+	for _, n := range []int{0, 1, 5, 43, len(hello) / 2, len(hello) - 1} {
+		if name, ok := ExtractSNI(hello[:n]); ok {
+			t.Errorf("truncated to %d bytes: ExtractSNI = %q, true", n, name)
+		}
+	}
+	if _, ok := ExtractSNI([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")); ok {
+		t.Error("HTTP request reported a name")
+	}
+}
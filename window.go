@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxWindowScale is the largest shift count allowed by RFC 7323.
+const MaxWindowScale = 14
+
+// ScaledWindow returns the window in bytes after applying the window scale
+// negotiated on the SYN. Shifts above MaxWindowScale are treated as 14.
+func (p *Packet) ScaledWindow(scale uint8) uint32 {
+
+	if scale > MaxWindowScale {
+		scale = MaxWindowScale
+	}
+	return uint32(p.Window()) << scale
+}
+
+// WindowString formats the scaled window for logging,
+// e.g. "64.0 KiB (win=16384, scale=2)".
+func (p *Packet) WindowString(scale uint8) string {
+
+	return fmt.Sprintf("%s (win=%d, scale=%d)", binarySize(p.ScaledWindow(scale)), p.Window(), scale)
+}
+
+// binarySize formats n bytes with binary units.
+func binarySize(n uint32) string {
+
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// MaxScaledWindow is the 1 GiB ceiling RFC 7323 puts on a scaled window.
+const MaxScaledWindow = 1 << 30
+
+// ReceiveWindowBytes returns the scaled window advertised by p. It never
+// exceeds MaxScaledWindow: ScaledWindow caps the shift at MaxWindowScale, and
+// 0xffff<<14 is just under 1 GiB, so the RFC 7323 ceiling needs no separate
+// clamp.
+func ReceiveWindowBytes(p *Packet, scale uint8) uint32 {
+
+	return p.ScaledWindow(scale)
+}
+
+// Thresholds used by WindowClass, applied to the scaled window in bytes.
+const (
+	// TinyWindowMax is the largest window classed "tiny": under three
+	// full-size Ethernet segments, too small to keep a path busy.
+	TinyWindowMax = 4096
+	// LargeWindowMin is the smallest window classed "large".
+	LargeWindowMin = 1 << 20
+)
+
+// WindowClass buckets the scaled window as "zero", "tiny" (up to
+// TinyWindowMax), "large" (LargeWindowMin and up) or "normal".
+func (p *Packet) WindowClass(scale uint8) string {
+
+	switch w := p.ScaledWindow(scale); {
+	case w == 0:
+		return "zero"
+	case w <= TinyWindowMax:
+		return "tiny"
+	case w >= LargeWindowMin:
+		return "large"
+	}
+	return "normal"
+}
+
+// WindowEfficiency returns the mean fraction of the receiver's advertised
+// window that is filled by in-flight data, sampled at each data segment of
+// a flow. pkts holds both directions in capture order with payloadLens read
+// in parallel; the receiver is told apart by ports. A value near 1 means the
+// receive window is what limits the sender. Segments sent before the peer's
+// first ACK, or against a zero window, are not sampled.
+func WindowEfficiency(pkts []*Packet, payloadLens []int, scale uint8) float64 {
+
+	type peerState struct {
+		ack    uint32
+		window uint32
+		seen   bool
+	}
+	peers := make(map[[2]uint16]*peerState)
+	state := func(src, dst uint16) *peerState {
+		k := [2]uint16{src, dst}
+		if peers[k] == nil {
+			peers[k] = &peerState{}
+		}
+		return peers[k]
+	}
+
+	var sum float64
+	var samples int
+	for i, p := range pkts {
+		if i >= len(payloadLens) {
+			break
+		}
+		if p.hasFlag(FlagACK) {
+			s := state(p.SourcePort(), p.DestinationPort())
+			s.ack, s.window, s.seen = p.AckNumber(), p.ScaledWindow(scale), true
+		}
+		if payloadLens[i] == 0 {
+			continue
+		}
+		peer := state(p.DestinationPort(), p.SourcePort())
+		if !peer.seen || peer.window == 0 {
+			continue
+		}
+		flight := p.NextSeq(payloadLens[i]) - peer.ack
+		if int32(flight) < 0 {
+			continue
+		}
+		frac := float64(flight) / float64(peer.window)
+		if frac > 1 {
+			frac = 1
+		}
+		sum += frac
+		samples++
+	}
+
+	if samples == 0 {
+		return 0
+	}
+	return sum / float64(samples)
+}
+
+// RecommendWindowScale returns the smallest window scale shift that lets a
+// full window cover the bandwidth-delay product of a path with bandwidth
+// bytes per second and the given RTT, capped at MaxWindowScale.
+func RecommendWindowScale(bandwidth float64, rtt time.Duration) uint8 {
+
+	bdp := bandwidth * rtt.Seconds()
+	var scale uint8
+	for scale < MaxWindowScale && float64(uint32(0xffff)<<scale) < bdp {
+		scale++
+	}
+	return scale
+}
+
+// WindowHistogram counts the scaled windows of pkts in buckets of equal
+// width spanning zero to the largest window seen; the largest, which would
+// start a bucket of its own, is counted in the last one. It returns nil when
+// buckets is not positive.
+func WindowHistogram(pkts []*Packet, scale uint8, buckets int) []int {
+
+	if buckets <= 0 {
+		return nil
+	}
+
+	var max uint64
+	for _, p := range pkts {
+		if w := uint64(p.ScaledWindow(scale)); w > max {
+			max = w
+		}
+	}
+
+	hist := make([]int, buckets)
+	for _, p := range pkts {
+		i := 0
+		if max > 0 {
+			i = int(uint64(p.ScaledWindow(scale)) * uint64(buckets) / max)
+		}
+		if i == buckets {
+			i--
+		}
+		hist[i]++
+	}
+	return hist
+}
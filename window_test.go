@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowString(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1, 1, FlagACK, 16384)
+	want := "64.0 KiB (win=16384, scale=2)"
+	if got := p.WindowString(2); got != want {
+		t.Errorf("WindowString(2) = %q, want %q", got, want)
+	}
+}
+
+func TestReceiveWindowBytes(t *testing.T) {
+
+	p := buildPacket(46926, 443, 1, 1, FlagACK, 0xffff)
+	for _, scale := range []uint8{14, 15, 255} {
+		got := ReceiveWindowBytes(p, scale)
+		if got != 0xffff<<14 || got > MaxScaledWindow {
+			t.Errorf("scale %d: ReceiveWindowBytes = %d, want %d (at most %d)", scale, got, 0xffff<<14, MaxScaledWindow)
+		}
+	}
+	if got := ReceiveWindowBytes(p, 2); got != 0xffff<<2 {
+		t.Errorf("scale 2: ReceiveWindowBytes = %d, want %d", got, 0xffff<<2)
+	}
+}
+
+func TestWindowClass(t *testing.T) {
+
+	tests := []struct {
+		window uint16
+		scale  uint8
+		want   string
+	}{
+		{0, 7, "zero"},
+		{1024, 0, "tiny"},
+		{TinyWindowMax, 0, "tiny"},
+		{TinyWindowMax + 1, 0, "normal"},
+		{64240, 0, "normal"},
+		{8192, 7, "large"},
+	}
+	for _, tt := range tests {
+		p := buildPacket(46926, 443, 1, 1, FlagACK, tt.window)
+		if got := p.WindowClass(tt.scale); got != tt.want {
+			t.Errorf("window %d scale %d: WindowClass = %q, want %q", tt.window, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestWindowEfficiency(t *testing.T) {
+
+	pkts := []*Packet{
+		buildPacket(443, 46926, 5001, 1001, FlagACK, 4000),
+		buildPacket(46926, 443, 1001, 5001, FlagACK, 64240),
+		buildPacket(46926, 443, 2001, 5001, FlagACK, 64240),
+		buildPacket(443, 46926, 5001, 3001, FlagACK, 4000),
+		buildPacket(46926, 443, 3001, 5001, FlagACK, 64240),
+	}
+	lens := []int{0, 1000, 1000, 0, 4000}
+	// Flights of 1000, 2000 and 4000 bytes against a 4000-byte window.
+	if got, want := WindowEfficiency(pkts, lens, 0), (0.25+0.5+1)/3; got != want {
+		t.Errorf("WindowEfficiency = %v, want %v", got, want)
+	}
+	if got := WindowEfficiency(pkts[1:3], lens[1:3], 0); got != 0 {
+		t.Errorf("before any ACK: WindowEfficiency = %v, want 0", got)
+	}
+}
+
+func TestRecommendWindowScale(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		bandwidth float64
+		rtt       time.Duration
+		want      uint8
+	}{
+		{"LAN", 1e6, 10 * time.Millisecond, 0},
+		{"10 Gbit/s over 100ms", 1.25e9, 100 * time.Millisecond, 11},
+		{"beyond the cap", 1e12, time.Second, MaxWindowScale},
+	}
+	for _, tt := range tests {
+		if got := RecommendWindowScale(tt.bandwidth, tt.rtt); got != tt.want {
+			t.Errorf("%s: RecommendWindowScale = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWindowHistogram(t *testing.T) {
+
+	wins := func(ws ...uint16) []*Packet {
+		pkts := make([]*Packet, len(ws))
+		for i, w := range ws {
+			pkts[i] = buildPacket(443, 46926, 1, 1, FlagACK, w)
+		}
+		return pkts
+	}
+
+	tests := []struct {
+		name    string
+		pkts    []*Packet
+		scale   uint8
+		buckets int
+		want    []int
+	}{
+		{"two windows", wins(0, 1), 0, 4, []int{1, 0, 0, 1}},
+		{"midpoint", wins(0, 5, 10), 0, 10, []int{1, 0, 0, 0, 0, 1, 0, 0, 0, 1}},
+		{"varying", wins(1000, 8000, 16000, 30000, 64000, 65535), 0, 4, []int{3, 1, 0, 2}},
+		{"scaled", wins(1000, 65535), 14, 2, []int{1, 1}},
+		{"all zero", wins(0, 0), 0, 3, []int{2, 0, 0}},
+		{"one bucket", wins(10, 20), 0, 1, []int{2}},
+	}
+	for _, tt := range tests {
+		got := WindowHistogram(tt.pkts, tt.scale, tt.buckets)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: WindowHistogram = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: WindowHistogram = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if got := WindowHistogram(wins(1), 0, 0); got != nil {
+		t.Errorf("zero buckets: WindowHistogram = %v, want nil", got)
+	}
+}